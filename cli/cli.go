@@ -10,12 +10,27 @@ import (
 
 type Cli struct {
 	Csv       bool
+	Json      bool
+	Influx    bool
 	Batch     bool
 	Formatter Formatter
 	In        *In
 	Out       *Out
 }
 
+// OpenOutput opens the output file, if any. Commands call it after validating
+// their flags and before making any request. Output appended to a file which
+// isn't empty is written without a header, as in batch mode.
+func (c *Cli) OpenOutput() error {
+	if err := c.Out.Open(); err != nil {
+		return err
+	}
+	if c.Out.Appending() {
+		c.Batch = true
+	}
+	return nil
+}
+
 func New(
 	formatter Formatter,
 	out, err io.Writer,
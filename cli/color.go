@@ -0,0 +1,22 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+// Color is an ANSI foreground color code
+type Color string
+
+const (
+	ColorDefault Color = "39"
+	ColorRed     Color = "31"
+	ColorGreen   Color = "32"
+	ColorYellow  Color = "33"
+)
+
+// colorize wraps s in ANSI escape sequences. All colors produce sequences of
+// the same length, so tabwriter columns stay aligned as long as every cell of
+// a column is colorized.
+func colorize(s string, c Color) string {
+	return "\033[" + string(c) + "m" + s + "\033[0m"
+}
@@ -7,6 +7,7 @@ package cli
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,8 @@ type Formatter interface {
 	FormatDurationms(d time.Duration) string
 	// FormatDurations formats a duration as seconds
 	FormatDurations(d time.Duration) string
+	// FormatDurationDays formats a duration as days
+	FormatDurationDays(d time.Duration) string
 }
 
 type defaultFormatter struct{}
@@ -43,5 +46,66 @@ func (f *defaultFormatter) FormatDurationms(d time.Duration) string {
 }
 
 func (f *defaultFormatter) FormatDurations(d time.Duration) string {
-	return strconv.FormatInt(d.Milliseconds()/1000, 10)
+	return strconv.FormatInt(int64(d.Seconds()), 10)
+}
+
+func (f *defaultFormatter) FormatDurationDays(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours()/24, 'f', 1, 64)
+}
+
+type goDurationFormatter struct {
+	defaultFormatter
+}
+
+// GoDurationFormatter formats durations using time.Duration.String, e.g. 123ms
+func GoDurationFormatter() Formatter {
+	return &goDurationFormatter{}
+}
+
+func (f *goDurationFormatter) FormatDurationms(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func (f *goDurationFormatter) FormatDurations(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+type humanFormatter struct {
+	defaultFormatter
+}
+
+// HumanFormatter formats durations in the largest fitting unit, e.g. 450ms,
+// 2.3s or 34d
+func HumanFormatter() Formatter {
+	return &humanFormatter{}
+}
+
+func (f *humanFormatter) FormatDurationms(d time.Duration) string {
+	return humanDuration(d)
+}
+
+func (f *humanFormatter) FormatDurations(d time.Duration) string {
+	return humanDuration(d)
+}
+
+func (f *humanFormatter) FormatDurationDays(d time.Duration) string {
+	return humanDuration(d)
+}
+
+func humanDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + humanDuration(-d)
+	}
+	switch {
+	case d < time.Second:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	case d < time.Minute:
+		return strings.TrimSuffix(strconv.FormatFloat(d.Seconds(), 'f', 1, 64), ".0") + "s"
+	case d < time.Hour:
+		return strconv.Itoa(int(d.Minutes())) + "m"
+	case d < 24*time.Hour:
+		return strconv.Itoa(int(d.Hours())) + "h"
+	default:
+		return strconv.Itoa(int(d.Hours()/24)) + "d"
+	}
 }
@@ -5,11 +5,24 @@
 package cli
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
-type In struct{}
+// In parses the values of an input. The form of the first duration decides
+// how all durations of the input are read, so use a new In per input.
+type In struct {
+	// durationForm is the form of the durations read so far
+	durationForm string
+}
+
+// Forms of durations
+const (
+	durationBare  = "bare numbers"
+	durationUnits = "durations with units"
+)
 
 func (i *In) ParseInt(in string) (int, error) {
 	return strconv.Atoi(in)
@@ -23,12 +36,36 @@ func (i *In) ParseDurations(in string) (time.Duration, error) {
 	return i.parseDuration(in, time.Second)
 }
 
+// parseDuration parses a bare number in the given unit as written by the
+// default formatter, or a Go duration string like 123ms or a number of days
+// like 34d as written with --duration-unit go or --human. Bare numbers are
+// ambiguous between the units, so an input mixing both forms is an error.
 func (i *In) parseDuration(in string, multiplier time.Duration) (time.Duration, error) {
-	v, err := strconv.Atoi(in)
+	d, form, err := parseDurationForm(in, multiplier)
 	if err != nil {
 		return 0, err
 	}
-	return time.Duration(v) * multiplier, nil
+	if i.durationForm == "" {
+		i.durationForm = form
+	} else if form != i.durationForm {
+		return 0, fmt.Errorf("mixed duration formats: '%s' is not like the earlier %s", in, i.durationForm)
+	}
+	return d, nil
+}
+
+func parseDurationForm(in string, multiplier time.Duration) (time.Duration, string, error) {
+	if v, err := strconv.Atoi(in); err == nil {
+		return time.Duration(v) * multiplier, durationBare, nil
+	}
+	if d, err := time.ParseDuration(in); err == nil {
+		return d, durationUnits, nil
+	}
+	if days, found := strings.CutSuffix(in, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, durationUnits, nil
+		}
+	}
+	return 0, "", fmt.Errorf("invalid duration '%s'", in)
 }
 
 func (i *In) ParseTime(in string) (time.Time, error) {
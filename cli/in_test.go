@@ -0,0 +1,60 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDurationForms(t *testing.T) {
+	bare := &In{}
+	if d, err := bare.ParseDurationms("250"); err != nil || d != 250*time.Millisecond {
+		t.Errorf("ParseDurationms(250) = %s, %v, want 250ms", d, err)
+	}
+	// Bare cert validities are seconds
+	if d, err := bare.ParseDurations("86400"); err != nil || d != 24*time.Hour {
+		t.Errorf("ParseDurations(86400) = %s, %v, want 24h", d, err)
+	}
+
+	units := &In{}
+	if d, err := units.ParseDurationms("2.3s"); err != nil || d != 2300*time.Millisecond {
+		t.Errorf("ParseDurationms(2.3s) = %s, %v, want 2.3s", d, err)
+	}
+	if d, err := units.ParseDurations("34d"); err != nil || d != 34*24*time.Hour {
+		t.Errorf("ParseDurations(34d) = %s, %v, want 816h", d, err)
+	}
+}
+
+// An input mixing bare numbers and durations with units is rejected in
+// either order instead of guessing the unit per value
+func TestParseDurationMixedForms(t *testing.T) {
+	bare := &In{}
+	if _, err := bare.ParseDurationms("250"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bare.ParseDurationms("250ms"); err == nil {
+		t.Error("ParseDurationms(250ms) after a bare number succeeded, want an error")
+	}
+
+	units := &In{}
+	if _, err := units.ParseDurations("34d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := units.ParseDurations("2937600"); err == nil {
+		t.Error("ParseDurations(2937600) after a duration with unit succeeded, want an error")
+	}
+}
+
+func TestParseDurationInvalid(t *testing.T) {
+	in := &In{}
+	if _, err := in.ParseDurationms("fast"); err == nil {
+		t.Error("ParseDurationms(fast) succeeded, want an error")
+	}
+	// An invalid value doesn't decide the form
+	if _, err := in.ParseDurationms("250"); err != nil {
+		t.Errorf("ParseDurationms(250) after an invalid value: %v", err)
+	}
+}
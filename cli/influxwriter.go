@@ -0,0 +1,63 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfluxWriter writes points in the InfluxDB line protocol
+type InfluxWriter struct {
+	w *bufio.Writer
+}
+
+// InfluxField is an integer field of a point
+type InfluxField struct {
+	Key   string
+	Value int64
+}
+
+func newInfluxWriter(w io.Writer) *InfluxWriter {
+	return &InfluxWriter{
+		w: bufio.NewWriter(w),
+	}
+}
+
+// Write writes a line made of already escaped parts separated by spaces
+func (w *InfluxWriter) Write(record ...string) error {
+	_, err := w.w.WriteString(strings.Join(record, " ") + "\n")
+	return err
+}
+
+// Point writes a point. Tags are given as key, value pairs, tags with an empty
+// value are left out since the line protocol doesn't allow them.
+func (w *InfluxWriter) Point(measurement string, tags []string, fields []InfluxField, timestamp time.Time) error {
+	key := measurementEscaper.Replace(measurement)
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] == "" {
+			continue
+		}
+		key += "," + tagEscaper.Replace(tags[i]) + "=" + tagEscaper.Replace(tags[i+1])
+	}
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		values[i] = tagEscaper.Replace(f.Key) + "=" + strconv.FormatInt(f.Value, 10) + "i"
+	}
+	return w.Write(key, strings.Join(values, ","), strconv.FormatInt(timestamp.UnixNano(), 10))
+}
+
+func (w *InfluxWriter) Flush() {
+	w.w.Flush()
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `, "\n", `\n`)
+	// tagEscaper escapes tag keys, tag values and field keys
+	tagEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
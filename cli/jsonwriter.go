@@ -0,0 +1,39 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// JsonWriter writes one JSON value per line
+type JsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJsonWriter(w io.Writer) *JsonWriter {
+	bw := bufio.NewWriter(w)
+	return &JsonWriter{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+	}
+}
+
+// Write writes a record as a JSON array of strings
+func (w *JsonWriter) Write(record ...string) error {
+	return w.enc.Encode(record)
+}
+
+// Encode writes a value as JSON
+func (w *JsonWriter) Encode(v any) error {
+	return w.enc.Encode(v)
+}
+
+func (w *JsonWriter) Flush() {
+	w.w.Flush()
+}
@@ -5,6 +5,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -12,8 +13,69 @@ import (
 )
 
 type Out struct {
-	out io.Writer
-	err io.Writer
+	out   io.Writer
+	err   io.Writer
+	file  *os.File
+	color bool
+	// path and append are set by SetFile, the file is opened by Open
+	path   string
+	append bool
+	// appending is set if the output is appended to a file which isn't empty
+	appending bool
+}
+
+// SetFile directs the output to a file. The file is only opened by Open, so
+// commands can validate their flags before the file is truncated.
+func (o *Out) SetFile(path string, append bool) {
+	o.path = path
+	o.append = append
+}
+
+// Open opens the file set with SetFile, creating it if it doesn't exist. The
+// file is truncated unless it is appended to. Without a file Open does nothing.
+func (o *Out) Open() error {
+	if o.path == "" || o.file != nil {
+		return nil
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if o.append {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	path := o.path
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open output %s: %v", path, err)
+	}
+	if o.append {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("unable to open output %s: %v", path, err)
+		}
+		o.appending = info.Size() > 0
+	}
+	o.out = f
+	o.file = f
+	return nil
+}
+
+// Appending reports whether the output is appended to a file which already
+// has content, so no header should be written
+func (o *Out) Appending() bool {
+	return o.appending
+}
+
+// Close closes the file opened with Open
+func (o *Out) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Close()
+}
+
+// EnableColor enables colors in tables written to the output
+func (o *Out) EnableColor(enabled bool) {
+	o.color = enabled
 }
 
 func (o *Out) Errorf(format string, a ...any) {
@@ -30,6 +92,16 @@ func (o *Out) FailAndExitf(format string, a ...any) {
 	os.Exit(1)
 }
 
+// IsTerminal reports whether the output is written to a terminal
+func (o *Out) IsTerminal() bool {
+	f, ok := o.out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func (o *Out) Println(a ...any) {
 	fmt.Fprintln(o.out, a...)
 }
@@ -42,8 +114,36 @@ func (o *Out) NewCsvWriter(comma rune) *CsvWriter {
 	return newCsvWriter(o.out, comma)
 }
 
+func (o *Out) NewJsonWriter() *JsonWriter {
+	return newJsonWriter(o.out)
+}
+
+func (o *Out) NewInfluxWriter() *InfluxWriter {
+	return newInfluxWriter(o.out)
+}
+
 func (o *Out) NewTabwriter() *TabWriter {
+	w := newTabwriter(o.out)
+	w.color = o.color
+	return w
+}
+
+func (o *Out) NewPrometheusWriter() *PrometheusWriter {
+	return newPrometheusWriter(o.out)
+}
+
+// NewErrTabwriter creates a TabWriter writing to the error output
+func (o *Out) NewErrTabwriter() *TabWriter {
+	return newTabwriter(o.err)
+}
+
+// NewErrJsonEncoder creates a JSON encoder writing to the error output
+func (o *Out) NewErrJsonEncoder() *json.Encoder {
+	return json.NewEncoder(o.err)
+}
+
+func newTabwriter(w io.Writer) *TabWriter {
 	return &TabWriter{
-		tw: tabwriter.NewWriter(o.out, 10, 1, 3, ' ', tabwriter.TabIndent),
+		tw: tabwriter.NewWriter(w, 10, 1, 3, ' ', tabwriter.TabIndent),
 	}
 }
@@ -0,0 +1,58 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PrometheusWriter writes metrics in the Prometheus text exposition format
+type PrometheusWriter struct {
+	w *bufio.Writer
+}
+
+func newPrometheusWriter(w io.Writer) *PrometheusWriter {
+	return &PrometheusWriter{
+		w: bufio.NewWriter(w),
+	}
+}
+
+// Describe writes the HELP and TYPE lines of a metric. All samples of the
+// metric have to follow directly.
+func (w *PrometheusWriter) Describe(name, help, metricType string) {
+	w.w.WriteString("# HELP " + name + " " + help + "\n")
+	w.w.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+// Sample writes a sample of a metric. Labels are given as name, value pairs.
+func (w *PrometheusWriter) Sample(name string, value float64, labels ...string) {
+	w.w.WriteString(name)
+	if len(labels) > 0 {
+		w.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			w.w.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+		}
+		w.w.WriteByte('}')
+	}
+	w.w.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+func (w *PrometheusWriter) Flush() {
+	if err := w.w.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
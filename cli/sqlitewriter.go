@@ -0,0 +1,147 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// OpenSqlite opens a SQLite database file, creating it if it doesn't exist
+func OpenSqlite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database %s: %v", path, err)
+	}
+	// Wait for other processes writing to the same file
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to open database %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// SqliteWriter inserts records as rows of a SQLite table. Rows are written in
+// a transaction which is committed on Flush.
+type SqliteWriter struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	columns int
+	insert  string
+	err     error
+}
+
+// OpenSqliteWriter opens a database and creates the table if it doesn't exist.
+// Columns are given as definitions, e.g. "code INTEGER". Columns missing in an
+// existing table, e.g. one written by an older version, are added.
+func OpenSqliteWriter(path, table string, columns []string) (*SqliteWriter, error) {
+	db, err := OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(columns, ", "))); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create table %s in %s: %v", table, path, err)
+	}
+	existing, err := SqliteColumns(db, table)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to read table %s in %s: %v", table, path, err)
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = ColumnName(column)
+		if slices.Contains(existing, names[i]) {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to add column %s to table %s in %s: %v", names[i], table, path, err)
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return &SqliteWriter{
+		db:      db,
+		columns: len(columns),
+		insert:  fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), placeholders),
+	}, nil
+}
+
+// SqliteColumns returns the names of the columns of a table in their order
+func SqliteColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s') ORDER BY cid", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ColumnName returns the name of a column definition, e.g. "code" of
+// "code INTEGER"
+func ColumnName(definition string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(definition), " ")
+	return name
+}
+
+// Write inserts a record. Missing values are inserted as NULL, extra values
+// are dropped.
+func (w *SqliteWriter) Write(record ...string) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.tx == nil {
+		tx, err := w.db.Begin()
+		if err != nil {
+			w.err = err
+			return err
+		}
+		w.tx = tx
+	}
+	values := make([]any, w.columns)
+	for i := range values {
+		if i < len(record) {
+			values[i] = record[i]
+		}
+	}
+	if _, err := w.tx.Exec(w.insert, values...); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Flush commits the rows written so far
+func (w *SqliteWriter) Flush() {
+	if w.tx == nil {
+		return
+	}
+	if err := w.tx.Commit(); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.tx = nil
+}
+
+// Close flushes the writer, closes the database and returns the first error
+// that occurred while writing
+func (w *SqliteWriter) Close() error {
+	w.Flush()
+	if err := w.db.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
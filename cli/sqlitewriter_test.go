@@ -0,0 +1,65 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// A table written by an older version gets the new columns, rows of both
+// versions can be read back
+func TestSqliteWriterAddsMissingColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pings.db")
+	old, err := OpenSqliteWriter(path, "pings", []string{"monitor TEXT", "code INTEGER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Write("api", "200")
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenSqliteWriter(path, "pings", []string{"monitor TEXT", "code INTEGER", "protocol TEXT", "error_kind TEXT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write("web", "503", "HTTP/1.1", "timeout")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	columns, err := SqliteColumns(db, "pings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"monitor", "code", "protocol", "error_kind"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("columns = %v, want %v", columns, want)
+	}
+	rows, err := db.Query("SELECT monitor, code, protocol, error_kind FROM pings ORDER BY rowid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got [][]string
+	for rows.Next() {
+		values := make([]sql.NullString, 4)
+		if err := rows.Scan(&values[0], &values[1], &values[2], &values[3]); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, []string{values[0].String, values[1].String, values[2].String, values[3].String})
+	}
+	want := [][]string{{"api", "200", "", ""}, {"web", "503", "HTTP/1.1", "timeout"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
@@ -10,10 +10,29 @@ import (
 )
 
 type TabWriter struct {
-	tw *tabwriter.Writer
+	tw           *tabwriter.Writer
+	color        bool
+	colorColumn  int
+	columnColors map[string]Color
+}
+
+// ColorColumn colors the values of a column. Values without a color, like the
+// header, are written in the default color. Has no effect if color output is
+// disabled.
+func (w *TabWriter) ColorColumn(column int, colors map[string]Color) {
+	w.colorColumn = column
+	w.columnColors = colors
 }
 
 func (w *TabWriter) Write(record ...string) error {
+	if w.color && w.columnColors != nil && w.colorColumn < len(record) {
+		record = append([]string(nil), record...)
+		c, ok := w.columnColors[record[w.colorColumn]]
+		if !ok {
+			c = ColorDefault
+		}
+		record[w.colorColumn] = colorize(record[w.colorColumn], c)
+	}
 	if _, err := w.tw.Write([]byte(strings.Join(record, "\t") + "\n")); err != nil {
 		return err
 	}
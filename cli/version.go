@@ -0,0 +1,14 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cli
+
+// Version of httpmon, set at build time with
+// -ldflags "-X github.com/cfichtmueller/httpmon/cli.Version=v1.2.3"
+var Version = "dev"
+
+// UserAgent returns the default User-Agent header, e.g. httpmon/v1.2.3
+func UserAgent() string {
+	return "httpmon/" + Version
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+	"github.com/spf13/cobra"
+)
+
+type compareopts struct {
+	headers       bool
+	ignoreHeaders []string
+	ignoreBody    bool
+	trimSpace     bool
+	normalizeJSON bool
+}
+
+func NewCommand(mcli *cli.Cli) *cobra.Command {
+	opts := compareopts{}
+
+	cmd := &cobra.Command{
+		Use:   "compare URL_A URL_B",
+		Short: "Compare the responses of two endpoints",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runCompare(mcli, opts, args[0], args[1]); err != nil {
+				mcli.Out.FailAndExit(err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.headers, "headers", false, "compare response headers")
+	flags.StringSliceVar(&opts.ignoreHeaders, "ignore-headers", []string{"Date"}, "headers to ignore when comparing headers")
+	flags.BoolVar(&opts.ignoreBody, "ignore-body", false, "don't compare response bodies")
+	flags.BoolVar(&opts.trimSpace, "trim-space", false, "ignore leading and trailing whitespace of bodies")
+	flags.BoolVar(&opts.normalizeJSON, "normalize-json", false, "compare bodies as JSON, ignoring formatting and key order")
+
+	return cmd
+}
+
+func runCompare(mcli *cli.Cli, opts compareopts, urlA, urlB string) error {
+	if err := mcli.OpenOutput(); err != nil {
+		return err
+	}
+
+	pings := make([]*engine.Ping, 2)
+	wait := &sync.WaitGroup{}
+	for i, u := range []string{urlA, urlB} {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			pings[i] = engine.ExecutePing(&engine.Monitor{
+				Name:                "compare",
+				URL:                 u,
+				ConnectTimeout:      5 * time.Second,
+				ResponseTimeout:     5 * time.Second,
+				MaxRedirects:        3,
+				AcceptedStatusCodes: []int{200, 201, 202, 204},
+				HTTPMethod:          "GET",
+				Headers:             map[string]string{"User-Agent": cli.UserAgent()},
+				CaptureResponse:     true,
+			})
+		}()
+	}
+	wait.Wait()
+
+	a, b := pings[0], pings[1]
+	for _, p := range pings {
+		if p.StatusCode == 0 {
+			return fmt.Errorf("%s: %s", p.URL, p.Message)
+		}
+	}
+
+	equal := true
+	if a.StatusCode != b.StatusCode {
+		equal = false
+		mcli.Out.Printf("Status codes differ: %d != %d\n", a.StatusCode, b.StatusCode)
+	}
+
+	if opts.headers {
+		for _, d := range diffHeaders(a.ResponseHeader, b.ResponseHeader, opts.ignoreHeaders) {
+			equal = false
+			mcli.Out.Println(d)
+		}
+	}
+
+	if !opts.ignoreBody {
+		bodyA, err := normalizeBody(a.ResponseBody, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %v", a.URL, err)
+		}
+		bodyB, err := normalizeBody(b.ResponseBody, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %v", b.URL, err)
+		}
+		if d := diffBodies(bodyA, bodyB); d != "" {
+			equal = false
+			mcli.Out.Println(d)
+		}
+	}
+
+	if !equal {
+		os.Exit(1)
+	}
+	mcli.Out.Println("Responses are equal")
+	return nil
+}
+
+// diffHeaders describes the headers which differ, ignoring the given header names
+func diffHeaders(a, b http.Header, ignore []string) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	diffs := make([]string, 0)
+	for _, name := range names {
+		if slices.ContainsFunc(ignore, func(i string) bool { return strings.EqualFold(i, name) }) {
+			continue
+		}
+		va, vb := strings.Join(a.Values(name), ", "), strings.Join(b.Values(name), ", ")
+		if va != vb {
+			diffs = append(diffs, fmt.Sprintf("Header %s differs: '%s' != '%s'", name, va, vb))
+		}
+	}
+	return diffs
+}
+
+func normalizeBody(body []byte, opts compareopts) ([]byte, error) {
+	if opts.normalizeJSON {
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		// Marshalling sorts object keys
+		return json.MarshalIndent(v, "", "  ")
+	}
+	if opts.trimSpace {
+		return bytes.TrimSpace(body), nil
+	}
+	return body, nil
+}
+
+// diffBodies describes the first line in which the bodies differ
+func diffBodies(a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	linesA := strings.Split(string(a), "\n")
+	linesB := strings.Split(string(b), "\n")
+	for i := 0; i < max(len(linesA), len(linesB)); i++ {
+		var la, lb string
+		if i < len(linesA) {
+			la = linesA[i]
+		}
+		if i < len(linesB) {
+			lb = linesB[i]
+		}
+		if la != lb {
+			return fmt.Sprintf("Bodies differ in line %d:\n- %s\n+ %s", i+1, truncate(la), truncate(lb))
+		}
+	}
+	return "Bodies differ"
+}
+
+// truncate shortens long lines for display
+func truncate(s string) string {
+	const maxLen = 200
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenSource obtains bearer tokens from a command and caches them
+type tokenSource struct {
+	command string
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newTokenSource(command string, ttl time.Duration) *tokenSource {
+	return &tokenSource{
+		command: command,
+		ttl:     ttl,
+	}
+}
+
+// Token returns the cached token or runs the command if the token expired
+func (t *tokenSource) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+
+	cmd := exec.Command("sh", "-c", t.command)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("auth command failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("auth command returned no token")
+	}
+
+	t.token = token
+	t.expires = time.Now().Add(t.ttl)
+	return token, nil
+}
+
+// Invalidate drops the token, unless it has been refreshed already
+func (t *tokenSource) Invalidate(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token == token {
+		t.token = ""
+	}
+}
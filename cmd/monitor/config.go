@@ -0,0 +1,160 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the structure of a monitor config file
+type config struct {
+	// Defaults apply to all monitors unless a monitor overrides them
+	Defaults monitorConfig   `json:"defaults" yaml:"defaults"`
+	Monitors []monitorConfig `json:"monitors" yaml:"monitors"`
+}
+
+// monitorConfig defines a monitor. Fields that aren't set keep their previous value.
+type monitorConfig struct {
+	Enabled             *bool             `json:"enabled" yaml:"enabled"` // false skips the monitor
+	Group               string            `json:"group" yaml:"group"`     // recorded as the group label
+	Name                string            `json:"name" yaml:"name"`
+	URL                 string            `json:"url" yaml:"url"`
+	Method              string            `json:"method" yaml:"method"`
+	Headers             map[string]string `json:"headers" yaml:"headers"`
+	Body                string            `json:"body" yaml:"body"`
+	ContentType         string            `json:"contentType" yaml:"contentType"`
+	ConnectTimeout      string            `json:"connectTimeout" yaml:"connectTimeout"`
+	Timeout             string            `json:"timeout" yaml:"timeout"`
+	MaxRedirects        *int              `json:"maxRedirects" yaml:"maxRedirects"`
+	Retries             *int              `json:"retries" yaml:"retries"`
+	RetryInterval       *int              `json:"retryInterval" yaml:"retryInterval"`
+	AcceptedStatusCodes []int             `json:"acceptedStatusCodes" yaml:"acceptedStatusCodes"`
+}
+
+// loadConfig reads the monitors from a JSON or YAML file. Each monitor starts
+// from the template, then the defaults and finally the monitor's own fields are
+// applied. Fields override each other, headers are merged key by key. Disabled
+// monitors are skipped and counted.
+func loadConfig(path string, template engine.Monitor) ([]engine.Monitor, int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read config %s: %v", path, err)
+	}
+
+	var c config
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &c)
+	default:
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+
+	if err := c.Defaults.apply(&template); err != nil {
+		return nil, 0, fmt.Errorf("invalid defaults in config %s: %v", path, err)
+	}
+
+	monitors := make([]engine.Monitor, 0, len(c.Monitors))
+	skipped := 0
+	for i, mc := range c.Monitors {
+		if !mc.enabled(c.Defaults) {
+			skipped++
+			continue
+		}
+		monitor := template
+		if err := mc.apply(&monitor); err != nil {
+			return nil, 0, fmt.Errorf("invalid monitor %d in config %s: %v", i+1, path, err)
+		}
+		if monitor.URL == "" {
+			return nil, 0, fmt.Errorf("invalid monitor %d in config %s: url is missing", i+1, path)
+		}
+		monitors = append(monitors, monitor)
+	}
+	return monitors, skipped, nil
+}
+
+// enabled reports whether the monitor is enabled, falling back to the defaults
+func (c *monitorConfig) enabled(defaults monitorConfig) bool {
+	if c.Enabled != nil {
+		return *c.Enabled
+	}
+	return defaults.Enabled == nil || *defaults.Enabled
+}
+
+// apply sets the fields of the monitor which are set in the config
+func (c *monitorConfig) apply(m *engine.Monitor) error {
+	if c.Name != "" {
+		m.Name = c.Name
+	}
+	if c.Group != "" {
+		labels := maps.Clone(m.Labels)
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels["group"] = c.Group
+		m.Labels = labels
+	}
+	if c.URL != "" {
+		m.URL = c.URL
+	}
+	if c.Method != "" {
+		method, err := parseMethod(c.Method)
+		if err != nil {
+			return err
+		}
+		m.HTTPMethod = method
+	}
+	if len(c.Headers) > 0 {
+		headers := maps.Clone(m.Headers)
+		if headers == nil {
+			headers = make(map[string]string, len(c.Headers))
+		}
+		maps.Copy(headers, c.Headers)
+		m.Headers = headers
+	}
+	if c.Body != "" {
+		m.Body = []byte(c.Body)
+	}
+	if c.ContentType != "" {
+		m.ContentType = c.ContentType
+	}
+	if c.ConnectTimeout != "" {
+		d, err := time.ParseDuration(c.ConnectTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid connectTimeout: %v", err)
+		}
+		m.ConnectTimeout = d
+	}
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+		m.ResponseTimeout = d
+	}
+	if c.MaxRedirects != nil {
+		m.MaxRedirects = *c.MaxRedirects
+	}
+	if c.Retries != nil {
+		m.Retries = *c.Retries
+	}
+	if c.RetryInterval != nil {
+		m.RetryInterval = *c.RetryInterval
+	}
+	if len(c.AcceptedStatusCodes) > 0 {
+		m.AcceptedStatusCodes = c.AcceptedStatusCodes
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// exitCodes maps the outcome of a run to the process exit code
+type exitCodes struct {
+	ok   int
+	warn int
+	fail int
+	// certExpiry, if set, is the code for runs whose warnings include
+	// expiring certificates, other warnings exit with warn
+	certExpiry int
+}
+
+func defaultExitCodes() exitCodes {
+	return exitCodes{ok: 0, warn: 0, fail: 1}
+}
+
+// parseExitCodeMap parses a mapping like fail=2,warn=1,ok=0. Outcomes which
+// aren't mapped keep their default.
+func parseExitCodeMap(spec string) (exitCodes, error) {
+	codes := defaultExitCodes()
+	for _, entry := range strings.Split(spec, ",") {
+		outcome, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return codes, fmt.Errorf("invalid exit code mapping '%s', expected outcome=code", entry)
+		}
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 0 || code > 125 {
+			return codes, fmt.Errorf("invalid exit code '%s' for %s, must be between 0 and 125", value, outcome)
+		}
+		switch outcome {
+		case "ok":
+			codes.ok = code
+		case "warn":
+			codes.warn = code
+		case "fail":
+			codes.fail = code
+		default:
+			return codes, fmt.Errorf("invalid outcome '%s', must be one of ok, warn, fail", outcome)
+		}
+	}
+	return codes, nil
+}
+
+// forRun returns the exit code for the worst status of a run and whether a
+// ping warned about an expiring certificate
+func (c exitCodes) forRun(status string, certExpiring bool) int {
+	if status == engine.StatusWarning && certExpiring && c.certExpiry != 0 {
+		return c.certExpiry
+	}
+	switch status {
+	case engine.StatusFailed:
+		return c.fail
+	case engine.StatusWarning:
+		return c.warn
+	default:
+		return c.ok
+	}
+}
+
+// severity orders statuses, the highest severity determines the outcome of a run
+func severity(status string) int {
+	switch status {
+	case engine.StatusSuccess:
+		return 0
+	case engine.StatusWarning:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"testing"
+
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+func TestExitCodesForRun(t *testing.T) {
+	// --fail-on-error --cert-warn
+	failOnError := defaultExitCodes()
+	failOnError.certExpiry = 2
+	exitCodeMap, err := parseExitCodeMap("warn=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		codes        exitCodes
+		status       string
+		certExpiring bool
+		want         int
+	}{
+		{"fail-on-error ok", failOnError, engine.StatusSuccess, false, 0},
+		{"fail-on-error expiring certificate", failOnError, engine.StatusWarning, true, 2},
+		{"fail-on-error unverified certificate", failOnError, engine.StatusWarning, false, 0},
+		{"fail-on-error failed", failOnError, engine.StatusFailed, true, 1},
+		{"exit-code-map expiring certificate", exitCodeMap, engine.StatusWarning, true, 1},
+		{"exit-code-map unverified certificate", exitCodeMap, engine.StatusWarning, false, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.codes.forRun(tt.status, tt.certExpiring); got != tt.want {
+			t.Errorf("%s: forRun = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// writeInfluxPoint writes a ping as a point of the httpmon measurement, tagged
+// by url, monitor, status and the monitor's labels. Timings are integer
+// milliseconds, the certificate validity is in seconds.
+func writeInfluxPoint(w *cli.InfluxWriter, p *engine.Ping) {
+	tags := append([]string{"url", p.URL, "monitor", p.Name, "status", p.Status}, labelPairs(p)...)
+	fields := []cli.InfluxField{
+		{Key: "status_code", Value: int64(p.StatusCode)},
+		{Key: "dns_ms", Value: p.DNSTime.Milliseconds()},
+		{Key: "connection_ms", Value: p.ConnectionTime.Milliseconds()},
+		{Key: "tls_ms", Value: p.TLSTime.Milliseconds()},
+		{Key: "ttfb_ms", Value: p.TTFB.Milliseconds()},
+		{Key: "download_ms", Value: p.DownloadTime.Milliseconds()},
+		{Key: "response_ms", Value: p.TotalResponseTime.Milliseconds()},
+		{Key: "response_size", Value: p.ResponseSize},
+	}
+	if p.CertChecked {
+		fields = append(fields, cli.InfluxField{Key: "cert_validity_s", Value: int64(p.CertRemainingValidity.Seconds())})
+	}
+	w.Point("httpmon", tags, fields, p.Timestamp)
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// limiter limits the number of pings in flight, overall and per host. A limit
+// of 0 means no limit.
+type limiter struct {
+	global  chan struct{}
+	perHost int
+	mu      sync.Mutex
+	hosts   map[string]chan struct{}
+}
+
+func newLimiter(concurrency, perHost int) *limiter {
+	l := &limiter{
+		perHost: perHost,
+		hosts:   make(map[string]chan struct{}),
+	}
+	if concurrency > 0 {
+		l.global = make(chan struct{}, concurrency)
+	}
+	return l
+}
+
+// host returns the semaphore of a host, creating it on first use
+func (l *limiter) host(name string) chan struct{} {
+	if l.perHost <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := l.hosts[name]
+	if !ok {
+		sem = make(chan struct{}, l.perHost)
+		l.hosts[name] = sem
+	}
+	return sem
+}
+
+// limited executes pings once both a slot of the host and a global slot are
+// available. The host slot is taken first so pings waiting for a busy host
+// don't block pings of other hosts. If the context is done while waiting for
+// a slot, the ping isn't executed and nil is returned.
+func limited(l *limiter, execute func(context.Context, *engine.Monitor) *engine.Ping) func(context.Context, *engine.Monitor) *engine.Ping {
+	return func(ctx context.Context, monitor *engine.Monitor) *engine.Ping {
+		hostname := monitor.URL
+		if u, err := url.Parse(monitor.URL); err == nil {
+			hostname = u.Hostname()
+		}
+		if sem := l.host(hostname); sem != nil {
+			if !acquire(ctx, sem) {
+				return nil
+			}
+			defer func() { <-sem }()
+		}
+		if l.global != nil {
+			if !acquire(ctx, l.global) {
+				return nil
+			}
+			defer func() { <-l.global }()
+		}
+		return execute(ctx, monitor)
+	}
+}
+
+// acquire takes a slot of the semaphore, unless the context is done first
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
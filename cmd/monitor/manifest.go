@@ -0,0 +1,108 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// manifest describes what a run checked and how it was configured
+type manifest struct {
+	Version  string            `json:"version"`
+	Flags    map[string]string `json:"flags"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	URLs     []string          `json:"urls"`
+	Monitors []manifestMonitor `json:"monitors"`
+}
+
+// manifestMonitor is the resolved configuration of a monitor
+type manifestMonitor struct {
+	Name                string            `json:"name"`
+	URL                 string            `json:"url"`
+	Method              string            `json:"method"`
+	Headers             map[string]string `json:"headers,omitempty"`
+	ConnectTimeout      string            `json:"connectTimeout"`
+	Timeout             string            `json:"timeout"`
+	MaxRedirects        int               `json:"maxRedirects"`
+	Retries             int               `json:"retries"`
+	RetryInterval       int               `json:"retryInterval"`
+	AcceptedStatusCodes []int             `json:"acceptedStatusCodes"`
+}
+
+// redactedHeaders are headers whose values aren't written to the manifest
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// redactedFlags are flags whose values may contain secrets and aren't written
+// to the manifest. The resolved headers are listed per monitor with secrets
+// redacted.
+var redactedFlags = []string{"header", "body", "auth-command"}
+
+func newManifest(flags map[string]string, start time.Time, monitors []engine.Monitor) *manifest {
+	flags = maps.Clone(flags)
+	for _, name := range redactedFlags {
+		if _, ok := flags[name]; ok {
+			flags[name] = "REDACTED"
+		}
+	}
+	if p, ok := flags["proxy"]; ok {
+		// Don't write the proxy's password
+		if u, err := url.Parse(p); err == nil {
+			flags["proxy"] = u.Redacted()
+		}
+	}
+	m := &manifest{
+		Version:  cli.Version,
+		Flags:    flags,
+		Start:    start.UTC(),
+		URLs:     make([]string, 0, len(monitors)),
+		Monitors: make([]manifestMonitor, 0, len(monitors)),
+	}
+	for _, monitor := range monitors {
+		headers := maps.Clone(monitor.Headers)
+		for k := range headers {
+			if slices.Contains(redactedHeaders, http.CanonicalHeaderKey(k)) {
+				headers[k] = "REDACTED"
+			}
+		}
+		m.URLs = append(m.URLs, monitor.URL)
+		m.Monitors = append(m.Monitors, manifestMonitor{
+			Name:                monitor.Name,
+			URL:                 monitor.URL,
+			Method:              monitor.HTTPMethod,
+			Headers:             headers,
+			ConnectTimeout:      monitor.ConnectTimeout.String(),
+			Timeout:             monitor.ResponseTimeout.String(),
+			MaxRedirects:        monitor.MaxRedirects,
+			Retries:             monitor.Retries,
+			RetryInterval:       monitor.RetryInterval,
+			AcceptedStatusCodes: monitor.AcceptedStatusCodes,
+		})
+	}
+	return m
+}
+
+// write sets the end time and writes the manifest as JSON
+func (m *manifest) write(path string, end time.Time) error {
+	m.End = end.UTC()
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("unable to write manifest %s: %v", path, err)
+	}
+	return nil
+}
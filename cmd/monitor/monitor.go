@@ -5,23 +5,101 @@
 package monitor
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/cmd/summarize"
 	"github.com/cfichtmueller/httpmon/engine"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type monitoropts struct {
-	file string
-	name string
-	urls []string
+	file            string
+	name            string
+	urls            []string
+	stripQuery      bool
+	finalURL        string
+	jsonSchema      string
+	expectBody      string
+	expectBodyRegex string
+	contentType     string
+	paths           []string
+	noCrossHost     bool
+	summary         bool
+	requireCert     bool
+	streamTimeout   time.Duration
+	config          string
+	sorted          bool
+	maxConns        int
+	maxIdleConns    int
+	nameHeader      string
+	acceptEncoding  string
+	exitCodeMap     string
+	failOnError     bool
+	certWarn        time.Duration
+	authCommand     string
+	authTTL         time.Duration
+	preflight       bool
+	preflightOrigin string
+	preflightMethod string
+	requestIDHeader string
+	localAddr       string
+	resolver        string
+	proxy           string
+	retries         int
+	retryInterval   int
+	retryBudget     time.Duration
+	waterfall       bool
+	idleTimeout     time.Duration
+	failOnRedirect  bool
+	noFollow        bool
+	db              string
+	prometheus      bool
+	watch           bool
+	quiet           bool
+	verbose         int
+	expectCache     string
+	concurrency     int
+	retryAssertion  bool
+	maxBodySize     int64
+	flushInterval   time.Duration
+	body            string
+	bodyFile        string
+	bodyType        string
+	headers         []string
+	userAgent       string
+	method          string
+	expectStatus    string
+	connectTimeout  time.Duration
+	timeout         time.Duration
+	http2           bool
+	interval        time.Duration
+	count           int
+	insecure        bool
+	caCert          string
+	perHost         int
+	manifest        string
+	flags           map[string]string
 }
 
 func NewCommand(mcli *cli.Cli) *cobra.Command {
@@ -34,6 +112,10 @@ func NewCommand(mcli *cli.Cli) *cobra.Command {
 			if len(args) > 0 {
 				opts.urls = args
 			}
+			opts.flags = make(map[string]string)
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				opts.flags[f.Name] = f.Value.String()
+			})
 			if err := runMonitor(mcli, opts); err != nil {
 				mcli.Out.FailAndExit(err)
 			}
@@ -43,11 +125,76 @@ func NewCommand(mcli *cli.Cli) *cobra.Command {
 	flags := cmd.Flags()
 	flags.StringVarP(&opts.file, "file", "f", "", "file to read URLs from")
 	flags.StringVarP(&opts.name, "name", "n", "", "name of the monitor")
+	flags.StringVarP(&opts.config, "config", "c", "", "JSON or YAML file defining the monitors")
+	flags.StringVar(&opts.nameHeader, "name-header", "", "send the monitor name in a header (use --name-header=Name to choose the header)")
+	flags.Lookup("name-header").NoOptDefVal = "X-Monitor-Name"
+	flags.StringVar(&opts.authCommand, "auth-command", "", "command printing a bearer token to send in the Authorization header")
+	flags.DurationVar(&opts.authTTL, "auth-ttl", 5*time.Minute, "how long to use a token from the auth command")
+	flags.StringVar(&opts.requestIDHeader, "request-id-header", "X-Request-Id", "header to send a unique ID per request in, empty to disable")
+	flags.BoolVar(&opts.preflight, "preflight", false, "send a CORS preflight request and check that origin and method are allowed")
+	flags.StringVar(&opts.preflightOrigin, "preflight-origin", "", "origin of the CORS preflight request")
+	flags.StringVar(&opts.preflightMethod, "preflight-method", "GET", "method of the CORS preflight request")
+	flags.StringVarP(&opts.method, "method", "X", "GET", "HTTP method of the request, e.g. HEAD or POST")
+	flags.StringVar(&opts.expectStatus, "expect-status", "200,201,202,204", "accepted status codes and ranges, e.g. 200-299,301")
+	flags.StringVar(&opts.userAgent, "user-agent", cli.UserAgent(), "User-Agent header to send")
+	flags.StringArrayVarP(&opts.headers, "header", "H", nil, "header to send, e.g. \"Accept: application/json\", can be repeated")
+	flags.StringVar(&opts.body, "body", "", "body to send with the request")
+	flags.StringVar(&opts.bodyFile, "body-file", "", "file with the body to send with the request")
+	flags.StringVar(&opts.bodyType, "content-type", "", "Content-Type of the body")
+	flags.StringVar(&opts.acceptEncoding, "accept-encoding", "", "Accept-Encoding header to send, e.g. identity or gzip (disables transparent decompression)")
+	flags.StringVar(&opts.finalURL, "expect-final-url", "", "pattern the URL after following redirects must match")
+	flags.StringArrayVar(&opts.paths, "path", nil, "path to check on each URL, can be repeated")
+	flags.StringVar(&opts.contentType, "expect-content-type", "", "media type the response must have, e.g. application/json")
+	flags.BoolVar(&opts.failOnRedirect, "fail-on-redirect", false, "fail pings that are redirected instead of following the redirect")
+	flags.BoolVar(&opts.noFollow, "no-follow", false, "report redirect responses instead of following them")
+	flags.StringVar(&opts.expectCache, "expect-cache", "", "cache status the response must have (hit or miss)")
+	flags.BoolVar(&opts.noCrossHost, "no-cross-host-redirect", false, "fail pings that are redirected to a different host")
+	flags.StringVar(&opts.expectBody, "expect-body", "", "text the response body must contain")
+	flags.StringVar(&opts.expectBodyRegex, "expect-body-regex", "", "pattern the response body must match")
+	flags.StringVar(&opts.jsonSchema, "json-schema", "", "JSON schema file to validate response bodies against")
+	flags.BoolVar(&opts.requireCert, "require-cert-info", false, "fail https pings when certificate information is missing")
+	flags.Int64Var(&opts.maxBodySize, "max-body-size", engine.DefaultMaxBodySize, "maximum number of bytes of a response body to read")
+	flags.DurationVar(&opts.connectTimeout, "connect-timeout", 5*time.Second, "time to connect, also applied to the TLS handshake")
+	flags.DurationVar(&opts.timeout, "timeout", 5*time.Second, "time for the whole request including the download of the body")
+	flags.BoolVar(&opts.http2, "http2", false, "attempt to negotiate HTTP/2 with TLS endpoints")
+	flags.DurationVar(&opts.streamTimeout, "stream-timeout", 0, "time to wait for the first event of an event stream (default: response timeout)")
+	flags.DurationVar(&opts.idleTimeout, "idle-timeout", 0, "fail if no bytes of the body arrive for this long, instead of limiting the whole download by the response timeout")
+	flags.BoolVar(&opts.retryAssertion, "retry-on-assertion", false, "also retry pings whose response failed a check, e.g. --json-schema")
+	flags.IntVar(&opts.retries, "retries", 2, "number of times to retry a failed ping (0 to attempt it once)")
+	flags.IntVar(&opts.retryInterval, "retry-interval", 10, "seconds to wait between retries")
+	flags.DurationVar(&opts.retryBudget, "retry-budget", 0, "maximum time to spend retrying a URL (0 for no limit)")
+	flags.BoolVar(&opts.insecure, "insecure", false, "don't verify TLS certificates, successful pings are reported as warnings")
+	flags.DurationVar(&opts.certWarn, "cert-warn", 0, "report pings as warnings if the certificate expires within this time, e.g. 168h")
+	flags.StringVar(&opts.caCert, "cacert", "", "PEM file with the CA certificates to verify TLS certificates with")
+	flags.StringVar(&opts.localAddr, "local-addr", "", "local IP address to send requests from")
+	flags.StringVar(&opts.proxy, "proxy", "", "HTTP, HTTPS or SOCKS5 proxy to send requests through, e.g. socks5://localhost:1080 (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY)")
+	flags.StringVar(&opts.resolver, "resolver", "", "DNS server to resolve host names with instead of the system's resolver, e.g. 1.1.1.1:53")
+	flags.IntVar(&opts.concurrency, "concurrency", 0, "maximum number of pings in flight (0 for no limit)")
+	flags.IntVar(&opts.perHost, "per-host-concurrency", 0, "maximum number of pings in flight per host (0 for no limit)")
+	flags.IntVar(&opts.maxConns, "max-conns-per-host", 0, "maximum connections per host (0 for no limit)")
+	flags.IntVar(&opts.maxIdleConns, "max-idle-conns-per-host", 0, "maximum idle connections kept per host when connections are reused")
+	flags.StringVar(&opts.exitCodeMap, "exit-code-map", "", "exit with a code per outcome, e.g. fail=2,warn=1,ok=0 (highest severity wins)")
+	flags.BoolVar(&opts.failOnError, "fail-on-error", false, "exit with 1 if any ping failed")
+	flags.DurationVar(&opts.interval, "interval", 0, "ping each URL at this interval until interrupted or --count is reached")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "only output pings which didn't succeed, and the header only if there are any")
+	flags.BoolVar(&opts.quiet, "only-failures", false, "same as --quiet")
+	flags.CountVarP(&opts.verbose, "verbose", "v", "log the events of each request to stderr, repeat for more details")
+	flags.BoolVar(&opts.watch, "watch", false, "with --interval, redraw a table of the latest ping per URL instead of appending rows")
+	flags.IntVar(&opts.count, "count", 0, "with --interval, number of times to ping each URL (0 for no limit)")
+	flags.DurationVar(&opts.flushInterval, "flush-interval", 0, "write buffered results at this interval (default: after each ping with --interval, otherwise when all pings completed)")
+	flags.BoolVar(&opts.sorted, "sorted", false, "write results sorted by URL and time after all pings completed")
+	flags.BoolVar(&opts.waterfall, "waterfall", false, "write the absolute time of each trace event per ping as JSON lines to stderr")
+	flags.BoolVar(&opts.prometheus, "prometheus", false, "write the results as Prometheus metrics, e.g. for the node_exporter textfile collector")
+	flags.StringVar(&opts.db, "db", "", "insert the results into this SQLite database instead of writing them to stdout")
+	flags.StringVar(&opts.manifest, "manifest", "", "write the flags, resolved monitors, version and run time as JSON to this file")
+	flags.BoolVar(&opts.summary, "summary", false, "print summary statistics to stderr after the run")
+	flags.BoolVar(&opts.stripQuery, "strip-query", false, "remove query strings from the reported URL (the request still uses the full URL)")
 
 	return cmd
 }
 
 func runMonitor(mcli *cli.Cli, opts monitoropts) error {
+	start := time.Now()
 	name := opts.name
 	if name == "" {
 		n, err := os.Hostname()
@@ -57,39 +204,291 @@ func runMonitor(mcli *cli.Cli, opts monitoropts) error {
 		name = n
 	}
 
+	var expectFinalURL *regexp.Regexp
+	if opts.finalURL != "" {
+		re, err := regexp.Compile(opts.finalURL)
+		if err != nil {
+			return fmt.Errorf("invalid final URL pattern '%s': %v", opts.finalURL, err)
+		}
+		expectFinalURL = re
+	}
+
+	var expectBodyRegex *regexp.Regexp
+	if opts.expectBodyRegex != "" {
+		re, err := regexp.Compile(opts.expectBodyRegex)
+		if err != nil {
+			return fmt.Errorf("invalid body pattern '%s': %v", opts.expectBodyRegex, err)
+		}
+		expectBodyRegex = re
+	}
+
+	var jsonSchema *jsonschema.Schema
+	if opts.jsonSchema != "" {
+		schema, err := jsonschema.Compile(opts.jsonSchema)
+		if err != nil {
+			return fmt.Errorf("unable to load JSON schema %s: %v", opts.jsonSchema, err)
+		}
+		jsonSchema = schema
+	}
+
+	var preflight *engine.Preflight
+	if opts.preflight {
+		if opts.preflightOrigin == "" {
+			return fmt.Errorf("--preflight requires --preflight-origin")
+		}
+		preflight = &engine.Preflight{
+			Origin: opts.preflightOrigin,
+			Method: strings.ToUpper(opts.preflightMethod),
+		}
+	}
+
+	var proxy *url.URL
+	if opts.proxy != "" {
+		u, err := url.Parse(opts.proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy '%s'", opts.proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid proxy scheme '%s', must be one of http, https, socks5", u.Scheme)
+		}
+		proxy = u
+	}
+
+	resolver := opts.resolver
+	if resolver != "" {
+		r, err := parseResolver(resolver)
+		if err != nil {
+			return err
+		}
+		resolver = r
+	}
+
+	var localAddr net.IP
+	if opts.localAddr != "" {
+		ip, err := checkLocalAddr(opts.localAddr)
+		if err != nil {
+			return err
+		}
+		localAddr = ip
+	}
+
+	if opts.expectCache != "" && opts.expectCache != engine.CacheHit && opts.expectCache != engine.CacheMiss {
+		return fmt.Errorf("invalid expected cache status '%s', expected hit or miss", opts.expectCache)
+	}
+
+	if opts.count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+	if opts.count > 0 && opts.interval <= 0 {
+		return fmt.Errorf("--count requires --interval")
+	}
+
+	var body []byte
+	if opts.body != "" && opts.bodyFile != "" {
+		return fmt.Errorf("cannot use --body and --body-file simultaneously")
+	}
+	if opts.body != "" {
+		body = []byte(opts.body)
+	}
+	if opts.bodyFile != "" {
+		b, err := os.ReadFile(opts.bodyFile)
+		if err != nil {
+			return fmt.Errorf("unable to read body file %s: %v", opts.bodyFile, err)
+		}
+		body = b
+	}
+
+	method, err := parseMethod(opts.method)
+	if err != nil {
+		return err
+	}
+
+	if opts.retries < 0 || opts.retryInterval < 0 {
+		return fmt.Errorf("retries and retry interval must not be negative")
+	}
+
+	if opts.connectTimeout <= 0 || opts.timeout <= 0 {
+		return fmt.Errorf("timeouts must be positive")
+	}
+
+	acceptedStatusCodes, err := parseStatusCodes(opts.expectStatus)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{"User-Agent": opts.userAgent}
+	for _, h := range opts.headers {
+		key, value, err := parseHeader(h)
+		if err != nil {
+			return err
+		}
+		// Replace a default header even if the case differs
+		for k := range headers {
+			if strings.EqualFold(k, key) {
+				delete(headers, k)
+			}
+		}
+		headers[key] = value
+	}
+
+	var tlsConfig *tls.Config
+	if opts.insecure || opts.caCert != "" {
+		tlsConfig = &tls.Config{InsecureSkipVerify: opts.insecure}
+	}
+	if opts.caCert != "" {
+		pem, err := os.ReadFile(opts.caCert)
+		if err != nil {
+			return fmt.Errorf("unable to read CA certificates %s: %v", opts.caCert, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", opts.caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	var codes *exitCodes
+	if opts.failOnError && opts.exitCodeMap != "" {
+		return fmt.Errorf("--fail-on-error and --exit-code-map cannot be used together")
+	}
+	if opts.failOnError {
+		c := defaultExitCodes()
+		if opts.certWarn > 0 {
+			c.certExpiry = 2
+		}
+		codes = &c
+	}
+	if opts.exitCodeMap != "" {
+		c, err := parseExitCodeMap(opts.exitCodeMap)
+		if err != nil {
+			return err
+		}
+		codes = &c
+	}
+
 	var writer Writer
+	var jsonWriter *cli.JsonWriter
+	var dbWriter *cli.SqliteWriter
+	var influxWriter *cli.InfluxWriter
 
-	if mcli.Csv {
-		writer = mcli.Out.NewCsvWriter(';')
-	} else {
-		writer = mcli.Out.NewTabwriter()
+	if opts.db != "" && (mcli.Json || mcli.Influx) {
+		return fmt.Errorf("--db cannot be used with --json or --influx")
+	}
+	if opts.watch && (opts.interval <= 0 || mcli.Csv || mcli.Json || mcli.Influx || opts.db != "" || opts.prometheus || opts.sorted) {
+		return fmt.Errorf("--watch requires --interval and table output")
+	}
+	if opts.quiet && (opts.watch || opts.prometheus) {
+		return fmt.Errorf("--quiet cannot be used with --watch or --prometheus")
+	}
+	if opts.prometheus && (mcli.Json || mcli.Influx || opts.db != "" || opts.interval > 0) {
+		return fmt.Errorf("--prometheus cannot be used with --json, --influx, --db or --interval")
 	}
 
-	wait := &sync.WaitGroup{}
-	urls := opts.urls
+	template := engine.Monitor{
+		Name:                name,
+		Retries:             opts.retries,
+		RetryInterval:       opts.retryInterval,
+		ConnectTimeout:      opts.connectTimeout,
+		ResponseTimeout:     opts.timeout,
+		MaxRedirects:        3,
+		AcceptedStatusCodes: acceptedStatusCodes,
+		HTTPMethod:          method,
+		Headers:             headers,
+		Body:                body,
+		ContentType:         opts.bodyType,
+		LocalAddr:           localAddr,
+		Resolver:            resolver,
+		Proxy:               proxy,
+		TLSConfig:           tlsConfig,
+		HTTP2:               opts.http2,
+		MaxConnsPerHost:     opts.maxConns,
+		MaxIdleConnsPerHost: opts.maxIdleConns,
+		AcceptEncoding:      opts.acceptEncoding,
+		MaxBodySize:         opts.maxBodySize,
+		IdleTimeout:         opts.idleTimeout,
+		RetryBudget:         opts.retryBudget,
+		RetryOnAssertion:    opts.retryAssertion,
+		Waterfall:           opts.waterfall,
+		RequestIDHeader:     opts.requestIDHeader,
+		Preflight:           preflight,
+		StreamTimeout:       opts.streamTimeout,
+		StripQuery:          opts.stripQuery,
+		ExpectFinalURL:      expectFinalURL,
+		RequireCertInfo:     opts.requireCert,
+		CertWarn:            opts.certWarn,
+		NoCrossHostRedirect: opts.noCrossHost,
+		FailOnRedirect:      opts.failOnRedirect,
+		NoFollow:            opts.noFollow,
+		ExpectCache:         opts.expectCache,
+		ExpectContentType:   opts.contentType,
+		ExpectBody:          opts.expectBody,
+		ExpectBodyRegex:     expectBodyRegex,
+		JSONSchema:          jsonSchema,
+	}
 
+	if opts.config != "" && (opts.file != "" || len(opts.urls) > 0) {
+		return fmt.Errorf("cannot use a config file and URLs simultaneously")
+	}
 	if opts.file != "" && len(opts.urls) > 0 {
 		return fmt.Errorf("cannot use URLs from file and arguments simultaneously")
 	}
-	if opts.file != "" {
-		b, err := os.ReadFile(opts.file)
+
+	var monitors []engine.Monitor
+	if opts.config != "" {
+		m, skipped, err := loadConfig(opts.config, template)
 		if err != nil {
-			return fmt.Errorf("unable to read file %s: %v", opts.file, err)
+			return err
+		}
+		if skipped > 0 {
+			mcli.Out.Errorf("Skipped %d disabled monitors\n", skipped)
+		}
+		monitors = m
+	} else {
+		targets := make([]target, 0, len(opts.urls))
+		for _, u := range opts.urls {
+			targets = append(targets, target{url: u})
+		}
+		if opts.file != "" {
+			t, err := readTargets(opts.file)
+			if err != nil {
+				return err
+			}
+			targets = t
+		}
+		for _, t := range targets {
+			if t.url == "" {
+				continue
+			}
+			monitor := template
+			monitor.URL = t.url
+			if t.name != "" {
+				monitor.Name = t.name
+			}
+			monitors = append(monitors, monitor)
 		}
-		urls = strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
 	}
 
-	invalid := false
-	for _, ru := range urls {
-		if ru == "" {
-			continue
+	if len(monitors) == 0 {
+		return fmt.Errorf("no URLs to monitor")
+	}
+
+	if opts.nameHeader != "" {
+		for i := range monitors {
+			monitors[i].Headers = maps.Clone(monitors[i].Headers)
+			monitors[i].Headers[opts.nameHeader] = monitors[i].Name
 		}
-		u, err := url.Parse(ru)
+	}
+
+	invalid := false
+	for _, m := range monitors {
+		u, err := url.Parse(m.URL)
 		if err != nil {
-			mcli.Out.Errorf("Invalid url '%s': %v\n", ru, err)
+			mcli.Out.Errorf("Invalid url '%s': %v\n", m.URL, err)
 			invalid = true
 		} else if u.Scheme != "http" && u.Scheme != "https" {
-			mcli.Out.Errorf("Invalid url '%s'\n", ru)
+			mcli.Out.Errorf("Invalid url '%s'\n", m.URL)
 			invalid = true
 		}
 	}
@@ -97,52 +496,294 @@ func runMonitor(mcli *cli.Cli, opts monitoropts) error {
 		os.Exit(1)
 	}
 
-	if !mcli.Batch {
-		writer.Write(
-			"MONITOR",
-			"URL",
-			"STATUS",
-			"TIMESTAMP",
-			"CODE",
-			"MESSAGE",
-			"DNS",
-			"CONNECTION",
-			"TLS",
-			"TTFB",
-			"DOWNLOAD",
-			"RESPONSE",
-			"CERT VALIDITY",
-		)
-	}
-
-	for _, u := range urls {
-		if u == "" {
-			continue
+	for _, m := range monitors {
+		if m.Body != nil && (m.HTTPMethod == http.MethodGet || m.HTTPMethod == http.MethodHead) {
+			mcli.Out.Errorf("Warning: sending a body with %s to %s\n", m.HTTPMethod, m.URL)
+		}
+	}
+
+	var runManifest *manifest
+	if opts.manifest != "" {
+		runManifest = newManifest(opts.flags, start, monitors)
+	}
+
+	// The output is opened once everything is validated, so a mistake doesn't
+	// truncate the output file
+	if err := mcli.OpenOutput(); err != nil {
+		return err
+	}
+	if opts.db != "" {
+		w, err := cli.OpenSqliteWriter(opts.db, "pings", summarize.DBColumns)
+		if err != nil {
+			return err
+		}
+		dbWriter = w
+		writer = dbWriter
+	} else if mcli.Json {
+		jsonWriter = mcli.Out.NewJsonWriter()
+		writer = jsonWriter
+	} else if mcli.Influx {
+		influxWriter = mcli.Out.NewInfluxWriter()
+		writer = influxWriter
+	} else if mcli.Csv {
+		writer = mcli.Out.NewCsvWriter(';')
+	} else {
+		writer = newPingTabwriter(mcli)
+	}
+
+	// Without a terminal the table is appended to instead of redrawn
+	var view *watchView
+	if opts.watch && mcli.Out.IsTerminal() {
+		view = newWatchView(mcli)
+	}
+
+	// With --quiet the header is written before the first row, if there is one
+	header := !mcli.Batch && !mcli.Json && !mcli.Influx && dbWriter == nil && !opts.prometheus && view == nil
+	if header && !opts.quiet {
+		writer.Write(pingHeader(!mcli.Csv)...)
+		header = false
+	}
+
+	output := func(ping *engine.Ping) {
+		if opts.quiet && ping.Status == engine.StatusSuccess {
+			return
+		}
+		if header {
+			writer.Write(pingHeader(!mcli.Csv)...)
+			header = false
+		}
+		if jsonWriter != nil {
+			jsonWriter.Encode(ping)
+		} else if influxWriter != nil {
+			writeInfluxPoint(influxWriter, ping)
+		} else {
+			writePing(writer, mcli.Formatter, ping, !mcli.Csv && dbWriter == nil)
+		}
+	}
+
+	waterfall := mcli.Out.NewErrJsonEncoder()
+	mu := &sync.Mutex{}
+	pings := make([]*engine.Ping, 0)
+	worst := engine.StatusSuccess
+	certExpiring := false
+	record := func(ping *engine.Ping) {
+		mu.Lock()
+		defer mu.Unlock()
+		if severity(ping.Status) > severity(worst) {
+			worst = ping.Status
+		}
+		certExpiring = certExpiring || ping.CertExpiring
+		if view != nil {
+			view.update(ping)
+		} else if !opts.sorted && !opts.prometheus {
+			output(ping)
+			if opts.interval > 0 && opts.flushInterval <= 0 {
+				writer.Flush()
+			}
+		}
+		if opts.waterfall {
+			waterfall.Encode(waterfallRecord{
+				Name:      ping.Name,
+				URL:       ping.URL,
+				RequestID: ping.RequestID,
+				Status:    ping.Status,
+				Events:    ping.Waterfall,
+			})
+		}
+		if opts.sorted || opts.summary || opts.prometheus {
+			pings = append(pings, ping)
 		}
+	}
+
+	if opts.flushInterval > 0 && !opts.sorted {
+		done := make(chan struct{})
+		defer close(done)
+		go flushPeriodically(mu, writer, opts.flushInterval, done)
+	}
+
+	eng := &engine.Engine{}
+	if opts.verbose > 0 {
+		eng.Logger = &verboseLogger{out: mcli.Out, verbosity: opts.verbose}
+	}
+	if opts.authCommand != "" {
+		eng.Tokens = newTokenSource(opts.authCommand, opts.authTTL)
+	}
+	execute := eng.ExecutePingContext
+	if opts.concurrency > 0 || opts.perHost > 0 {
+		execute = limited(newLimiter(opts.concurrency, opts.perHost), execute)
+	}
+
+	// Stop scheduling pings and abort the pings in progress on the first
+	// interrupt, a second one terminates
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	s := schedule{interval: opts.interval, count: opts.count}
+	wait := &sync.WaitGroup{}
+	for _, m := range monitors {
 		wait.Add(1)
-		go pingUrl(writer, mcli.Formatter, wait, name, u)
+		if len(opts.paths) > 0 {
+			// Paths of the same URL are checked one after the other to reuse the connection
+			m.KeepAlive = true
+			go pingMonitors(ctx, s, execute, record, wait, expandPaths(m, opts.paths))
+		} else {
+			go pingMonitors(ctx, s, execute, record, wait, []engine.Monitor{m})
+		}
 	}
 
 	wait.Wait()
+	if opts.sorted {
+		slices.SortStableFunc(pings, comparePings)
+	}
+	if opts.prometheus {
+		writePrometheus(mcli, pings)
+	} else if opts.sorted {
+		for _, ping := range pings {
+			output(ping)
+		}
+	}
+	mu.Lock()
 	writer.Flush()
+	mu.Unlock()
+	if dbWriter != nil {
+		if err := dbWriter.Close(); err != nil {
+			return fmt.Errorf("unable to write to database %s: %v", opts.db, err)
+		}
+	}
+
+	if opts.summary {
+		w := mcli.Out.NewErrTabwriter()
+		summarize.WriteSummary(mcli, w, engine.Summarize(pings), engine.GroupByURL, true, nil)
+		w.Flush()
+	}
+
+	if runManifest != nil {
+		if err := runManifest.write(opts.manifest, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if codes != nil {
+		if code := codes.forRun(worst, certExpiring); code != 0 {
+			os.Exit(code)
+		}
+	}
 	return nil
 }
 
-func pingUrl(w Writer, formatter cli.Formatter, wg *sync.WaitGroup, name, url string) {
-	monitor := &engine.Monitor{
-		Name:                name,
-		URL:                 url,
-		Retries:             2,
-		RetryInterval:       10,
-		ConnectTimeout:      5 * time.Second,
-		ResponseTimeout:     5 * time.Second,
-		MaxRedirects:        3,
-		AcceptedStatusCodes: []int{200, 201, 202, 204},
-		HTTPMethod:          "GET",
-		Headers:             map[string]string{"User-Agent": "HTTP-Monitor-Agent"},
+// schedule defines how often monitors are pinged. Without an interval they are
+// pinged once.
+type schedule struct {
+	interval time.Duration
+	count    int
+}
+
+// pingMonitors pings the monitors until the schedule ends or ctx is done. Pings
+// in progress are aborted when ctx is done.
+func pingMonitors(ctx context.Context, s schedule, execute func(context.Context, *engine.Monitor) *engine.Ping, record func(*engine.Ping), wg *sync.WaitGroup, monitors []engine.Monitor) {
+	defer wg.Done()
+
+	var ticker *time.Ticker
+	if s.interval > 0 {
+		ticker = time.NewTicker(s.interval)
+		defer ticker.Stop()
+	}
+	for i := 0; s.count == 0 || i < s.count; i++ {
+		for _, monitor := range monitors {
+			if ctx.Err() != nil {
+				return
+			}
+			// Pings waiting for a slot when ctx is done aren't executed
+			if ping := execute(ctx, &monitor); ping != nil {
+				record(ping)
+			}
+		}
+		if ticker == nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flushPeriodically flushes the writer until done is closed. The writer is
+// locked with mu, so only complete rows are flushed.
+func flushPeriodically(mu *sync.Mutex, w Writer, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mu.Lock()
+			w.Flush()
+			mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// statusColors colors the STATUS column of the table output
+var statusColors = map[string]cli.Color{
+	engine.StatusSuccess: cli.ColorGreen,
+	engine.StatusWarning: cli.ColorYellow,
+	engine.StatusFailed:  cli.ColorRed,
+}
+
+// newPingTabwriter creates a TabWriter for pings with a colored STATUS column
+func newPingTabwriter(mcli *cli.Cli) *cli.TabWriter {
+	w := mcli.Out.NewTabwriter()
+	w.ColorColumn(2, statusColors)
+	return w
+}
+
+// pingHeader returns the names of the columns written by writePing
+func pingHeader(table bool) []string {
+	return []string{
+		"MONITOR",
+		"URL",
+		"STATUS",
+		"TIMESTAMP",
+		"CODE",
+		"MESSAGE",
+		"DNS",
+		"CONNECTION",
+		"TLS",
+		"TTFB",
+		"DOWNLOAD",
+		"RESPONSE",
+		certValidityColumn(table),
+		"REQUEST ID",
+		"CACHE",
+		"CERT ISSUER",
+		"REMOTE ADDR",
+		"PROTOCOL",
+		"REDIRECTS",
+		"ERROR KIND",
+	}
+}
+
+// certValidityColumn names the cert validity column. Tables show days, CSV
+// keeps seconds so it can be summarized.
+func certValidityColumn(table bool) string {
+	if table {
+		return "CERT DAYS"
 	}
-	ping := engine.ExecutePing(monitor)
+	return "CERT VALIDITY"
+}
 
+func writePing(w Writer, formatter cli.Formatter, ping *engine.Ping, table bool) {
+	certValidity := formatter.FormatDurations(ping.CertRemainingValidity)
+	if table {
+		certValidity = formatter.FormatDurationDays(ping.CertRemainingValidity)
+	}
 	w.Write(
 		ping.Name,
 		ping.URL,
@@ -156,9 +797,187 @@ func pingUrl(w Writer, formatter cli.Formatter, wg *sync.WaitGroup, name, url st
 		formatter.FormatDurationms(ping.TTFB),
 		formatter.FormatDurationms(ping.DownloadTime),
 		formatter.FormatDurationms(ping.TotalResponseTime),
-		formatter.FormatDurations(ping.CertRemainingValidity),
+		certValidity,
+		ping.RequestID,
+		ping.CacheStatus,
+		ping.CertIssuer,
+		ping.RemoteAddr,
+		ping.Protocol,
+		formatRedirects(ping.Redirects),
+		ping.ErrorKind,
 	)
-	wg.Done()
+}
+
+// formatRedirects formats redirects as status code and URL per hop, e.g.
+// "301 http://example.com/ > 302 https://example.com/"
+func formatRedirects(redirects []engine.Redirect) string {
+	hops := make([]string, len(redirects))
+	for i, r := range redirects {
+		hops[i] = strconv.Itoa(r.StatusCode) + " " + r.URL
+	}
+	return strings.Join(hops, " > ")
+}
+
+// waterfallRecord is written per ping with --waterfall
+type waterfallRecord struct {
+	Name      string                  `json:"name"`
+	URL       string                  `json:"url"`
+	RequestID string                  `json:"requestId,omitempty"`
+	Status    string                  `json:"status"`
+	Events    []engine.WaterfallEvent `json:"events"`
+}
+
+// parseHeader parses a header in the form "Key: Value"
+func parseHeader(h string) (string, string, error) {
+	key, value, ok := strings.Cut(h, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", fmt.Errorf("invalid header '%s', expected 'Key: Value'", h)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+// parseMethod normalizes an HTTP method and makes sure it is known
+func parseMethod(m string) (string, error) {
+	method := strings.ToUpper(m)
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method, nil
+	default:
+		return "", fmt.Errorf("invalid method '%s'", m)
+	}
+}
+
+// parseStatusCodes parses a list of status codes and ranges like 200-299,301.
+// Ranges are expanded to the codes they contain.
+func parseStatusCodes(spec string) ([]int, error) {
+	codes := make([]int, 0)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		from, to, isRange := strings.Cut(entry, "-")
+		if !isRange {
+			to = from
+		}
+		first, err := parseStatusCode(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code '%s'", entry)
+		}
+		last, err := parseStatusCode(to)
+		if err != nil || last < first {
+			return nil, fmt.Errorf("invalid status code range '%s'", entry)
+		}
+		for code := first; code <= last; code++ {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+func parseStatusCode(s string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code '%s'", s)
+	}
+	return code, nil
+}
+
+// maxURLLength limits the length of lines in a URL file
+const maxURLLength = 64 << 10
+
+// target is a URL to monitor, optionally with its own monitor name
+type target struct {
+	name string
+	url  string
+}
+
+// parseTarget parses a line of a URL file. A line is either a URL or a name
+// and a URL separated by a comma or semicolon, e.g. api,https://example.com.
+// URLs have a scheme, so a separator before :// always ends a name.
+func parseTarget(line string) target {
+	line = strings.TrimSpace(line)
+	i := strings.IndexAny(line, ",;")
+	if i < 0 || strings.Contains(line[:i], "://") {
+		return target{url: line}
+	}
+	return target{name: strings.TrimSpace(line[:i]), url: strings.TrimSpace(line[i+1:])}
+}
+
+// readTargets reads one target per line from a file
+func readTargets(path string) ([]target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), maxURLLength)
+	targets := make([]target, 0)
+	line := 0
+	for scanner.Scan() {
+		line++
+		targets = append(targets, parseTarget(scanner.Text()))
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, fmt.Errorf("line %d of %s is longer than %d bytes", line+1, path, maxURLLength)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %v", path, err)
+	}
+	return targets, nil
+}
+
+// parseResolver parses the address of a DNS server, the port defaults to 53
+func parseResolver(addr string) (string, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return net.JoinHostPort(ip.String(), "53"), nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return "", fmt.Errorf("invalid resolver '%s', expected host:port", addr)
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", fmt.Errorf("invalid resolver port '%s'", port)
+	}
+	return addr, nil
+}
+
+// checkLocalAddr parses the address and makes sure it can be bound
+func checkLocalAddr(addr string) (net.IP, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid local address '%s'", addr)
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to bind local address %s: %v", addr, err)
+	}
+	l.Close()
+	return ip, nil
+}
+
+// comparePings orders pings by URL and timestamp
+func comparePings(a, b *engine.Ping) int {
+	if c := strings.Compare(a.URL, b.URL); c != 0 {
+		return c
+	}
+	return a.Timestamp.Compare(b.Timestamp)
+}
+
+// expandPaths creates a monitor for each path appended to the monitor's URL
+func expandPaths(monitor engine.Monitor, paths []string) []engine.Monitor {
+	u, err := url.Parse(monitor.URL)
+	if err != nil {
+		return []engine.Monitor{monitor}
+	}
+	monitors := make([]engine.Monitor, 0, len(paths))
+	for _, p := range paths {
+		m := monitor
+		m.URL = u.JoinPath(p).String()
+		monitors = append(monitors, m)
+	}
+	return monitors
 }
 
 type Writer interface {
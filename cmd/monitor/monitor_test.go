@@ -0,0 +1,176 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/cmd/summarize"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// newTestCli creates a Cli writing CSV to out
+func newTestCli(formatter cli.Formatter, out io.Writer) *cli.Cli {
+	mcli := cli.New(formatter, out, io.Discard)
+	mcli.Csv = true
+	return mcli
+}
+
+// The durations are chosen to survive the rounding of all formats
+func TestWritePingRoundTrip(t *testing.T) {
+	want := &engine.Ping{
+		Name:                  "api",
+		URL:                   "https://example.com/health?a=1",
+		Status:                engine.StatusWarning,
+		Timestamp:             time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC),
+		StatusCode:            200,
+		Message:               "OK; served \"stale\"",
+		DNSTime:               12 * time.Millisecond,
+		ConnectionTime:        34 * time.Millisecond,
+		TLSTime:               56 * time.Millisecond,
+		TTFB:                  780 * time.Millisecond,
+		DownloadTime:          9 * time.Millisecond,
+		TotalResponseTime:     2500 * time.Millisecond,
+		CertRemainingValidity: 34 * 24 * time.Hour,
+		CertChecked:           true,
+		CertIssuer:            "CN=Example CA,O=Example",
+		RequestID:             "5f0c6c1e-8a33-4b7e-9d51-0f1f5b0e7a10",
+		CacheStatus:           engine.CacheHit,
+		RemoteAddr:            "93.184.216.34:443",
+		Protocol:              "HTTP/2.0",
+		Redirects: []engine.Redirect{
+			{URL: "http://example.com/health?a=1", StatusCode: 301},
+			{URL: "https://example.com/health/?a=1", StatusCode: 308},
+		},
+	}
+	formatters := map[string]cli.Formatter{
+		"bare-ms": cli.DefaultFormatter(),
+		"go":      cli.GoDurationFormatter(),
+		"human":   cli.HumanFormatter(),
+	}
+	for name, formatter := range formatters {
+		buf := &bytes.Buffer{}
+		mcli := newTestCli(formatter, buf)
+		w := mcli.Out.NewCsvWriter(';')
+		w.Write(pingHeader(false)...)
+		writePing(w, formatter, want, false)
+		w.Flush()
+
+		r := summarize.NewPingReader(mcli, bytes.NewReader(buf.Bytes()))
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("%s: unable to read %q: %v", name, buf.String(), err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read\n%+v\nwant\n%+v", name, got, want)
+		}
+		if _, err := r.Next(); err != io.EOF {
+			t.Errorf("%s: got %v after the ping, want EOF", name, err)
+		}
+	}
+}
+
+// runCommand runs the monitor command with the arguments and returns its CSV
+// output
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	cmd := NewCommand(newTestCli(cli.DefaultFormatter(), buf))
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// readRows parses CSV output, checking that each row is complete
+func readRows(t *testing.T, output string) [][]string {
+	t.Helper()
+	r := csv.NewReader(strings.NewReader(output))
+	r.Comma = ';'
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("invalid output %q: %v", output, err)
+	}
+	columns := len(pingHeader(false))
+	for i, row := range rows {
+		if len(row) != columns {
+			t.Errorf("row %d has %d columns, want %d: %q", i+1, len(row), columns, row)
+		}
+	}
+	return rows
+}
+
+// Run with -race: the rows of concurrent pings must neither race nor interleave
+func TestConcurrentWrites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.IntN(5)) * time.Millisecond)
+		w.Write([]byte(strings.Repeat("x", 1024)))
+	}))
+	defer server.Close()
+
+	const n = 50
+	args := []string{"--retries", "0"}
+	want := make(map[string]bool, n)
+	for i := range n {
+		u := fmt.Sprintf("%s/?i=%d", server.URL, i)
+		args = append(args, u)
+		want[u] = true
+	}
+
+	rows := readRows(t, runCommand(t, args...))
+	if len(rows) != n+1 {
+		t.Fatalf("got %d rows, want a header and %d pings", len(rows), n)
+	}
+	for _, row := range rows[1:] {
+		if !want[row[1]] {
+			t.Errorf("unexpected or duplicate URL %s", row[1])
+		}
+		delete(want, row[1])
+		if row[2] != engine.StatusSuccess {
+			t.Errorf("%s: status %s, want %s", row[1], row[2], engine.StatusSuccess)
+		}
+	}
+}
+
+func TestURLFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	content := server.URL + "/a\n\napi," + server.URL + "/b\n" + server.URL + "/c\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file isn't the first argument, so it must be taken from the flag
+	rows := readRows(t, runCommand(t, "--retries", "0", "--sorted", "-n", "web", "-f", path))
+	want := [][2]string{
+		{"web", server.URL + "/a"},
+		{"api", server.URL + "/b"},
+		{"web", server.URL + "/c"},
+	}
+	if len(rows) != len(want)+1 {
+		t.Fatalf("got %d rows, want a header and %d pings", len(rows), len(want))
+	}
+	for i, w := range want {
+		if got := [2]string{rows[i+1][0], rows[i+1][1]}; got != w {
+			t.Errorf("row %d is %s, want %s", i+1, got, w)
+		}
+	}
+}
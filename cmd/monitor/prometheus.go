@@ -0,0 +1,80 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+type pingMetric struct {
+	name  string
+	help  string
+	value func(p *engine.Ping) float64
+	// only, if set, skips pings the metric doesn't apply to
+	only func(p *engine.Ping) bool
+}
+
+var pingMetrics = []pingMetric{
+	{"httpmon_up", "Whether the ping didn't fail.", func(p *engine.Ping) float64 {
+		if p.Status == engine.StatusFailed {
+			return 0
+		}
+		return 1
+	}, nil},
+	{"httpmon_status_code", "HTTP status code of the response, 0 if there was none.", func(p *engine.Ping) float64 { return float64(p.StatusCode) }, nil},
+	{"httpmon_dns_time_ms", "Time spent resolving DNS in milliseconds.", func(p *engine.Ping) float64 { return float64(p.DNSTime.Milliseconds()) }, nil},
+	{"httpmon_ttfb_ms", "Time to first byte in milliseconds.", func(p *engine.Ping) float64 { return float64(p.TTFB.Milliseconds()) }, nil},
+	{"httpmon_response_time_ms", "Total response time in milliseconds.", func(p *engine.Ping) float64 { return float64(p.TotalResponseTime.Milliseconds()) }, nil},
+	{"httpmon_cert_validity_seconds", "Remaining validity of the TLS certificate in seconds.", func(p *engine.Ping) float64 { return p.CertRemainingValidity.Seconds() }, func(p *engine.Ping) bool { return p.CertChecked }},
+}
+
+// writePrometheus writes the pings as Prometheus metrics labeled by monitor,
+// url and the monitor's labels. If a URL was pinged several times by the same
+// monitor, the last ping is written.
+func writePrometheus(mcli *cli.Cli, pings []*engine.Ping) {
+	type key struct{ name, url string }
+	latest := make(map[key]int, len(pings))
+	unique := make([]*engine.Ping, 0, len(pings))
+	for _, p := range pings {
+		k := key{p.Name, p.URL}
+		if i, ok := latest[k]; ok {
+			unique[i] = p
+			continue
+		}
+		latest[k] = len(unique)
+		unique = append(unique, p)
+	}
+
+	w := mcli.Out.NewPrometheusWriter()
+	for _, m := range pingMetrics {
+		w.Describe(m.name, m.help, "gauge")
+		for _, p := range unique {
+			if m.only != nil && !m.only(p) {
+				continue
+			}
+			w.Sample(m.name, m.value(p), metricLabels(p)...)
+		}
+	}
+	w.Flush()
+}
+
+// metricLabels returns the labels of a ping as name, value pairs
+func metricLabels(p *engine.Ping) []string {
+	return append([]string{"monitor", p.Name, "url", p.URL}, labelPairs(p)...)
+}
+
+// labelPairs returns the monitor's labels of a ping as name, value pairs
+// sorted by name
+func labelPairs(p *engine.Ping) []string {
+	pairs := make([]string, 0, 2*len(p.Labels))
+	for _, name := range slices.Sorted(maps.Keys(p.Labels)) {
+		pairs = append(pairs, name, p.Labels[name])
+	}
+	return pairs
+}
@@ -0,0 +1,24 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// verboseLogger writes the events of pings up to a verbosity level to the
+// error output
+type verboseLogger struct {
+	out       *cli.Out
+	verbosity int
+}
+
+func (l *verboseLogger) Log(level int, monitor *engine.Monitor, message string) {
+	if level > l.verbosity {
+		return
+	}
+	l.out.Errorf("%s: %s\n", monitor.URL, message)
+}
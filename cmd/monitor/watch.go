@@ -0,0 +1,61 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package monitor
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// watchView keeps the latest ping per monitor and URL and redraws them as a
+// table on each update
+type watchView struct {
+	mcli   *cli.Cli
+	latest map[watchKey]*engine.Ping
+}
+
+type watchKey struct {
+	name string
+	url  string
+}
+
+func newWatchView(mcli *cli.Cli) *watchView {
+	return &watchView{
+		mcli:   mcli,
+		latest: make(map[watchKey]*engine.Ping),
+	}
+}
+
+func (v *watchView) update(ping *engine.Ping) {
+	v.latest[watchKey{ping.Name, ping.URL}] = ping
+	v.render()
+}
+
+func (v *watchView) render() {
+	keys := make([]watchKey, 0, len(v.latest))
+	for k := range v.latest {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b watchKey) int {
+		if c := strings.Compare(a.url, b.url); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	v.mcli.Out.Printf(clearScreen)
+	w := newPingTabwriter(v.mcli)
+	w.Write(pingHeader(true)...)
+	for _, k := range keys {
+		writePing(w, v.mcli.Formatter, v.latest[k], true)
+	}
+	w.Flush()
+}
@@ -8,14 +8,22 @@ import (
 	"os"
 
 	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/cmd/compare"
 	"github.com/cfichtmueller/httpmon/cmd/monitor"
 	"github.com/cfichtmueller/httpmon/cmd/summarize"
 	"github.com/spf13/cobra"
 )
 
 type rootopts struct {
-	batch bool
-	csv   bool
+	batch        bool
+	csv          bool
+	json         bool
+	influx       bool
+	durationUnit string
+	noColor      bool
+	human        bool
+	output       string
+	append       bool
 }
 
 func Execute() error {
@@ -32,22 +40,73 @@ func newRootCommand() *cobra.Command {
 	opts := rootopts{}
 
 	cmd := &cobra.Command{
-		Use:   "httpmon",
-		Short: "A one-shot tool for monitoring HTTP and HTTPS endpoints.",
+		Use:     "httpmon",
+		Short:   "A one-shot tool for monitoring HTTP and HTTPS endpoints.",
+		Version: cli.Version,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			mcli.Batch = opts.batch
 			mcli.Csv = opts.csv
+			mcli.Json = opts.json
+			mcli.Influx = opts.influx
+			if countTrue(opts.csv, opts.json, opts.influx) > 1 {
+				mcli.Out.FailAndExitf("only one of --csv, --json and --influx can be used\n")
+			}
+			if opts.human {
+				if cmd.Flags().Changed("duration-unit") {
+					mcli.Out.FailAndExitf("--human cannot be used with --duration-unit\n")
+				}
+				mcli.Formatter = cli.HumanFormatter()
+			} else {
+				switch opts.durationUnit {
+				case "bare-ms":
+				case "go":
+					mcli.Formatter = cli.GoDurationFormatter()
+				default:
+					mcli.Out.FailAndExitf("invalid duration unit '%s', must be one of bare-ms, go\n", opts.durationUnit)
+				}
+			}
+			if opts.append && opts.output == "" {
+				mcli.Out.FailAndExitf("--append requires --output\n")
+			}
+			mcli.Out.EnableColor(opts.output == "" && !opts.noColor && os.Getenv("NO_COLOR") == "" && mcli.Out.IsTerminal())
+			// The file is opened by the command once its flags are validated
+			if opts.output != "" {
+				mcli.Out.SetFile(opts.output, opts.append)
+			}
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if err := mcli.Out.Close(); err != nil {
+				mcli.Out.FailAndExit(err)
+			}
 		},
 	}
 
 	persistentFlags := cmd.PersistentFlags()
 	persistentFlags.BoolVarP(&opts.batch, "batch", "b", false, "batch mode")
 	persistentFlags.BoolVar(&opts.csv, "csv", false, "produce csv output")
+	persistentFlags.BoolVar(&opts.json, "json", false, "produce JSON output")
+	persistentFlags.BoolVar(&opts.influx, "influx", false, "produce InfluxDB line protocol output")
+	persistentFlags.StringVarP(&opts.output, "output", "o", "", "write the output to a file instead of stdout")
+	persistentFlags.BoolVar(&opts.append, "append", false, "with --output, append to the file instead of truncating it")
+	persistentFlags.BoolVar(&opts.human, "human", false, "format durations in the largest fitting unit, e.g. 2.3s or 34d")
+	persistentFlags.BoolVar(&opts.noColor, "no-color", false, "disable colors in table output")
+	persistentFlags.StringVar(&opts.durationUnit, "duration-unit", "bare-ms", "format of durations: bare-ms or go (e.g. 123ms)")
 
 	cmd.AddCommand(
 		monitor.NewCommand(mcli),
 		summarize.NewCommand(mcli),
+		compare.NewCommand(mcli),
 	)
 
 	return cmd
 }
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
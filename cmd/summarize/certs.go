@@ -0,0 +1,77 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+type certRow struct {
+	endpoint string
+	issuer   string
+	expiry   time.Time
+}
+
+// certExpiries returns the certificate expiry of each https endpoint, based on
+// its latest measurement with certificate information, soonest first
+func certExpiries(pings []*engine.Ping) []certRow {
+	latest := make(map[string]*engine.Ping)
+	for _, p := range pings {
+		if !p.CertChecked {
+			continue
+		}
+		if u, err := url.Parse(p.URL); err != nil || u.Scheme != "https" {
+			continue
+		}
+		if l, ok := latest[p.URL]; !ok || p.Timestamp.After(l.Timestamp) {
+			latest[p.URL] = p
+		}
+	}
+
+	rows := make([]certRow, 0, len(latest))
+	for endpoint, p := range latest {
+		rows = append(rows, certRow{
+			endpoint: endpoint,
+			issuer:   p.CertIssuer,
+			expiry:   p.Timestamp.Add(p.CertRemainingValidity),
+		})
+	}
+	slices.SortFunc(rows, func(a, b certRow) int {
+		if c := a.expiry.Compare(b.expiry); c != 0 {
+			return c
+		}
+		return strings.Compare(a.endpoint, b.endpoint)
+	})
+	return rows
+}
+
+// writeCerts writes the certificate expiry of each https endpoint. If warn is
+// set, only certificates expiring within warn are written.
+func writeCerts(mcli *cli.Cli, pings []*engine.Ping, warn time.Duration, now time.Time) {
+	w := mcli.Out.NewTabwriter()
+	if !mcli.Batch {
+		w.Write("URL", "ISSUER", "DAYS LEFT", "EXPIRES")
+	}
+	for _, row := range certExpiries(pings) {
+		remaining := row.expiry.Sub(now)
+		if warn > 0 && remaining >= warn {
+			continue
+		}
+		w.Write(
+			row.endpoint,
+			row.issuer,
+			strconv.Itoa(int(remaining.Hours()/24)),
+			mcli.Formatter.FormatTime(row.expiry),
+		)
+	}
+	w.Flush()
+}
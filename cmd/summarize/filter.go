@@ -0,0 +1,85 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// filter reports whether a ping should be kept
+type filter func(p *engine.Ping) bool
+
+// buildFilters creates the filters selected by the options, in the order they
+// are applied: monitor, match, since, until, only failures
+func buildFilters(in *cli.In, opts summarizeopts, now time.Time) ([]filter, error) {
+	filters := make([]filter, 0)
+	if len(opts.monitors) > 0 {
+		monitors := opts.monitors
+		filters = append(filters, func(p *engine.Ping) bool {
+			return slices.Contains(monitors, p.Name)
+		})
+	}
+	if opts.match != "" {
+		re, err := regexp.Compile(opts.match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern '%s': %v", opts.match, err)
+		}
+		filters = append(filters, func(p *engine.Ping) bool {
+			return re.MatchString(p.URL)
+		})
+	}
+	if opts.since != "" {
+		since, err := parseTimeOrAgo(in, opts.since, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since '%s': %v", opts.since, err)
+		}
+		filters = append(filters, func(p *engine.Ping) bool {
+			return !p.Timestamp.Before(since)
+		})
+	}
+	if opts.until != "" {
+		until, err := parseTimeOrAgo(in, opts.until, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid until '%s': %v", opts.until, err)
+		}
+		filters = append(filters, func(p *engine.Ping) bool {
+			return p.Timestamp.Before(until)
+		})
+	}
+	if opts.onlyFailures {
+		filters = append(filters, func(p *engine.Ping) bool {
+			return p.Status == engine.StatusFailed
+		})
+	}
+	return filters, nil
+}
+
+// applyFilters keeps the pings matching all filters
+func applyFilters(pings []*engine.Ping, filters []filter) []*engine.Ping {
+	for _, f := range filters {
+		kept := make([]*engine.Ping, 0, len(pings))
+		for _, p := range pings {
+			if f(p) {
+				kept = append(kept, p)
+			}
+		}
+		pings = kept
+	}
+	return pings
+}
+
+// parseTimeOrAgo parses an RFC3339 time or a duration before now, e.g. 24h
+func parseTimeOrAgo(in *cli.In, s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return in.ParseTime(s)
+}
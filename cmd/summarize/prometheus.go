@@ -0,0 +1,62 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+type summaryMetric struct {
+	name  string
+	help  string
+	value func(s *engine.SummaryStats) float64
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Milliseconds())
+}
+
+var summaryMetrics = []summaryMetric{
+	{"httpmon_availability", "Ratio of successful measurements.", func(s *engine.SummaryStats) float64 { return s.Availability / 100 }},
+	{"httpmon_warning_ratio", "Ratio of measurements with warnings.", func(s *engine.SummaryStats) float64 { return s.WarningRate / 100 }},
+	{"httpmon_response_avg_ms", "Average response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.AvgResponseTime) }},
+	{"httpmon_response_median_ms", "Median response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.MedianResponseTime) }},
+	{"httpmon_response_p95_ms", "95th percentile of the response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.Percentile95ResponseTime) }},
+	{"httpmon_response_p99_ms", "99th percentile of the response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.Percentile99ResponseTime) }},
+	{"httpmon_response_max_ms", "Longest response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.LongestResponseTime) }},
+	{"httpmon_response_min_ms", "Shortest response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.ShortestResponseTime) }},
+	{"httpmon_response_stddev_ms", "Standard deviation of the response time in milliseconds.", func(s *engine.SummaryStats) float64 { return milliseconds(s.StdDevResponseTime) }},
+	{"httpmon_cert_validity_seconds", "Shortest remaining certificate validity in seconds.", func(s *engine.SummaryStats) float64 { return s.ShortestCertValidityTime.Seconds() }},
+	{"httpmon_cache_hit_ratio", "Ratio of cache hits among measurements with a known cache status.", func(s *engine.SummaryStats) float64 { return s.CacheHitRate / 100 }},
+	{"httpmon_measurements", "Number of measurements.", func(s *engine.SummaryStats) float64 { return float64(s.NumberOfMeasurements) }},
+	{"httpmon_failed_measurements", "Number of failed measurements.", func(s *engine.SummaryStats) float64 { return float64(s.NumberOfFailedMeasurements) }},
+}
+
+// writePrometheus writes summary statistics as Prometheus metrics labeled by
+// url and/or monitor, depending on how the statistics are grouped
+func writePrometheus(mcli *cli.Cli, allStats []*engine.SummaryStats) {
+	w := mcli.Out.NewPrometheusWriter()
+	for _, m := range summaryMetrics {
+		w.Describe(m.name, m.help, "gauge")
+		for _, stats := range allStats {
+			w.Sample(m.name, m.value(stats), statsLabels(stats)...)
+		}
+	}
+	w.Flush()
+}
+
+func statsLabels(stats *engine.SummaryStats) []string {
+	labels := []string{}
+	if stats.Monitor != "" {
+		labels = append(labels, "monitor", stats.Monitor)
+	}
+	if stats.Endpoint != "" {
+		labels = append(labels, "url", stats.Endpoint)
+	}
+	return labels
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// PingReader reads pings from monitoring results
+type PingReader interface {
+	// Next returns the next ping or io.EOF at the end of the input. After
+	// other errors reading can continue with the next record.
+	Next() (*engine.Ping, error)
+}
+
+// NewPingReader creates a reader for CSV or JSON lines input. Unless CSV is
+// requested explicitly, the input is detected by peeking at its first byte.
+func NewPingReader(mcli *cli.Cli, r io.Reader) PingReader {
+	br := bufio.NewReader(r)
+	if !mcli.Csv && isJSON(br) {
+		return newJsonPingReader(br)
+	}
+	return newCsvPingReader(br)
+}
+
+// isJSON peeks at the first non-whitespace byte without consuming the input
+func isJSON(br *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := br.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return b[n-1] == '{'
+		}
+	}
+}
+
+type csvPingReader struct {
+	in     *cli.In
+	reader *csv.Reader
+	line   int
+}
+
+func newCsvPingReader(r io.Reader) *csvPingReader {
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	// Newer versions append columns, so records may differ in length
+	cr.FieldsPerRecord = -1
+	return &csvPingReader{
+		in:     &cli.In{},
+		reader: cr,
+	}
+}
+
+func (r *csvPingReader) Next() (*engine.Ping, error) {
+	r.line += 1
+	record, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(record) < 13 {
+		return nil, fmt.Errorf("invalid record on line %d", r.line)
+	}
+	// Output of the monitor command without -b starts with a header
+	if r.line == 1 && record[0] == "MONITOR" && record[3] == "TIMESTAMP" {
+		return r.Next()
+	}
+	return parsePing(r.in, record)
+}
+
+type jsonPingReader struct {
+	scanner *bufio.Scanner
+	line    int
+	done    bool
+}
+
+func newJsonPingReader(r io.Reader) *jsonPingReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	return &jsonPingReader{
+		scanner: scanner,
+	}
+}
+
+func (r *jsonPingReader) Next() (*engine.Ping, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	for r.scanner.Scan() {
+		r.line += 1
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		p := &engine.Ping{}
+		if err := json.Unmarshal(line, p); err != nil {
+			return nil, fmt.Errorf("invalid record on line %d: %v", r.line, err)
+		}
+		return p, nil
+	}
+	// The scanner can't continue after an error
+	r.done = true
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func parsePing(in *cli.In, record []string) (*engine.Ping, error) {
+	timestamp, err := in.ParseTime(record[3])
+	if err != nil {
+		return nil, err
+	}
+	statusCode, err := in.ParseInt(record[4])
+	if err != nil {
+		return nil, err
+	}
+	dnsTime, err := in.ParseDurationms(record[6])
+	if err != nil {
+		return nil, err
+	}
+	connectionTime, err := in.ParseDurationms(record[7])
+	if err != nil {
+		return nil, err
+	}
+	tlsTime, err := in.ParseDurationms(record[8])
+	if err != nil {
+		return nil, err
+	}
+	ttfb, err := in.ParseDurationms(record[9])
+	if err != nil {
+		return nil, err
+	}
+	downloadTime, err := in.ParseDurationms(record[10])
+	if err != nil {
+		return nil, err
+	}
+	totalResponseTime, err := in.ParseDurationms(record[11])
+	if err != nil {
+		return nil, err
+	}
+	certRemainingValidity, err := in.ParseDurations(record[12])
+	if err != nil {
+		return nil, err
+	}
+	requestID := ""
+	if len(record) > 13 {
+		requestID = record[13]
+	}
+	cacheStatus := ""
+	if len(record) > 14 {
+		cacheStatus = record[14]
+	}
+	// Older records have no issuer column
+	certIssuer := ""
+	certChecked := certRemainingValidity != 0
+	if len(record) > 15 {
+		certIssuer = record[15]
+		certChecked = certIssuer != ""
+	}
+	remoteAddr, protocol := "", ""
+	var redirects []engine.Redirect
+	if len(record) > 18 {
+		remoteAddr = record[16]
+		protocol = record[17]
+		redirects, err = parseRedirects(record[18])
+		if err != nil {
+			return nil, err
+		}
+	}
+	errorKind := ""
+	if len(record) > 19 {
+		errorKind = record[19]
+	}
+	return &engine.Ping{
+		Name:                  record[0],
+		URL:                   record[1],
+		Status:                record[2],
+		Timestamp:             timestamp,
+		StatusCode:            statusCode,
+		Message:               record[5],
+		DNSTime:               dnsTime,
+		ConnectionTime:        connectionTime,
+		TLSTime:               tlsTime,
+		TTFB:                  ttfb,
+		DownloadTime:          downloadTime,
+		TotalResponseTime:     totalResponseTime,
+		CertRemainingValidity: certRemainingValidity,
+		CertChecked:           certChecked,
+		CertIssuer:            certIssuer,
+		RequestID:             requestID,
+		CacheStatus:           cacheStatus,
+		RemoteAddr:            remoteAddr,
+		Protocol:              protocol,
+		Redirects:             redirects,
+		ErrorKind:             errorKind,
+	}, nil
+
+}
+
+// parseRedirects parses the redirects column, status code and URL per hop,
+// e.g. "301 http://example.com/ > 302 https://example.com/"
+func parseRedirects(s string) ([]engine.Redirect, error) {
+	if s == "" {
+		return nil, nil
+	}
+	hops := strings.Split(s, " > ")
+	redirects := make([]engine.Redirect, len(hops))
+	for i, hop := range hops {
+		code, url, ok := strings.Cut(hop, " ")
+		statusCode, err := strconv.Atoi(code)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid redirect '%s'", hop)
+		}
+		redirects[i] = engine.Redirect{URL: url, StatusCode: statusCode}
+	}
+	return redirects, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// DBColumns defines the table of results written by monitor --db. The
+// columns match the CSV output.
+var DBColumns = []string{
+	"monitor TEXT",
+	"url TEXT",
+	"status TEXT",
+	"timestamp TEXT",
+	"code INTEGER",
+	"message TEXT",
+	"dns INTEGER",
+	"connection INTEGER",
+	"tls INTEGER",
+	"ttfb INTEGER",
+	"download INTEGER",
+	"response INTEGER",
+	"cert_validity INTEGER",
+	"request_id TEXT",
+	"cache TEXT",
+	"cert_issuer TEXT",
+	"remote_addr TEXT",
+	"protocol TEXT",
+	"redirects TEXT",
+	"error_kind TEXT",
+}
+
+// selectPings returns the query reading the rowid and the columns of DBColumns
+// in the order of the CSV output, of the rows after a rowid. Tables written by older versions lack the newer columns,
+// only the columns up to the first missing one are read.
+func selectPings(db *sql.DB) (string, int, error) {
+	existing, err := cli.SqliteColumns(db, "pings")
+	if err != nil {
+		return "", 0, err
+	}
+	var names []string
+	for _, column := range DBColumns {
+		name := cli.ColumnName(column)
+		if !slices.Contains(existing, name) {
+			break
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", 0, fmt.Errorf("no table pings")
+	}
+	return fmt.Sprintf("SELECT rowid, %s FROM pings WHERE rowid > ? ORDER BY rowid", strings.Join(names, ", ")), len(names), nil
+}
+
+// sqlitePingReader reads pings from a database written by monitor --db
+type sqlitePingReader struct {
+	in      *cli.In
+	db      *sql.DB
+	rows    *sql.Rows
+	columns int
+	row     int
+	// lastRowid is the rowid of the last row read
+	lastRowid int64
+}
+
+// newSqlitePingReader reads the rows after the rowid. If the table has no such
+// rowid anymore, e.g. because it was recreated, all rows are read.
+func newSqlitePingReader(path string, after int64) (*sqlitePingReader, error) {
+	db, err := cli.OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	query, columns, err := selectPings(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to read database %s: %v", path, err)
+	}
+	var maxRowid int64
+	if err := db.QueryRow("SELECT IFNULL(MAX(rowid), 0) FROM pings").Scan(&maxRowid); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to read database %s: %v", path, err)
+	}
+	if after > maxRowid {
+		after = 0
+	}
+	rows, err := db.Query(query, after)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to read database %s: %v", path, err)
+	}
+	return &sqlitePingReader{
+		in:        &cli.In{},
+		db:        db,
+		rows:      rows,
+		columns:   columns,
+		lastRowid: after,
+	}, nil
+}
+
+func (r *sqlitePingReader) Next() (*engine.Ping, error) {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	r.row += 1
+	values := make([]sql.NullString, r.columns)
+	dest := make([]any, len(values)+1)
+	dest[0] = &r.lastRowid
+	for i := range values {
+		dest[i+1] = &values[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, fmt.Errorf("invalid row %d: %v", r.row, err)
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = v.String
+	}
+	p, err := parsePing(r.in, record)
+	if err != nil {
+		return nil, fmt.Errorf("invalid row %d: %v", r.row, err)
+	}
+	return p, nil
+}
+
+// position returns the rowid of the last row read
+func (r *sqlitePingReader) position() int64 {
+	return r.lastRowid
+}
+
+func (r *sqlitePingReader) Close() error {
+	r.rows.Close()
+	return r.db.Close()
+}
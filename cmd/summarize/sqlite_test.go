@@ -0,0 +1,95 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// writeDB writes the records to the pings table with the columns
+func writeDB(t *testing.T, path string, columns []string, records ...[]string) {
+	w, err := cli.OpenSqliteWriter(path, "pings", columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		w.Write(record...)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readDB reads all pings of the database
+func readDB(t *testing.T, path string) []*engine.Ping {
+	return readDBAfter(t, path, 0)
+}
+
+// readDBAfter reads the pings of the database after the rowid
+func readDBAfter(t *testing.T, path string, after int64) []*engine.Ping {
+	r, err := newSqlitePingReader(path, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var pings []*engine.Ping
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return pings
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pings = append(pings, p)
+	}
+}
+
+var dbRecord = []string{
+	"api", "https://example.com/", "Failed", "2024-05-01T12:00:00Z", "0", "Error executing request",
+	"12", "0", "0", "0", "0", "5000", "0", "", "", "",
+	"93.184.216.34:443", "HTTP/1.1", "301 http://example.com/", "timeout",
+}
+
+func TestSqliteReadsAllColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pings.db")
+	writeDB(t, path, DBColumns, dbRecord)
+
+	pings := readDB(t, path)
+	if len(pings) != 1 {
+		t.Fatalf("read %d pings, want 1", len(pings))
+	}
+	p := pings[0]
+	if p.RemoteAddr != "93.184.216.34:443" || p.Protocol != "HTTP/1.1" || p.ErrorKind != engine.ErrorKindTimeout {
+		t.Errorf("RemoteAddr, Protocol, ErrorKind = %s, %s, %s", p.RemoteAddr, p.Protocol, p.ErrorKind)
+	}
+	if want := []engine.Redirect{{URL: "http://example.com/", StatusCode: 301}}; !reflect.DeepEqual(p.Redirects, want) {
+		t.Errorf("Redirects = %v, want %v", p.Redirects, want)
+	}
+	stats := engine.Summarize(pings)[0]
+	if want := map[string]int{engine.ErrorKindTimeout: 1}; !reflect.DeepEqual(stats.FailuresByKind, want) {
+		t.Errorf("FailuresByKind = %v, want %v", stats.FailuresByKind, want)
+	}
+}
+
+// A table written by an older version is read with the columns it has
+func TestSqliteReadsOlderTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pings.db")
+	writeDB(t, path, DBColumns[:16], dbRecord[:16])
+
+	pings := readDB(t, path)
+	if len(pings) != 1 {
+		t.Fatalf("read %d pings, want 1", len(pings))
+	}
+	if p := pings[0]; p.Name != "api" || p.TotalResponseTime.Milliseconds() != 5000 || p.ErrorKind != "" {
+		t.Errorf("read %+v", p)
+	}
+}
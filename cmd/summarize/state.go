@@ -0,0 +1,115 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// state is persisted between summarize runs
+type state struct {
+	// LastTimestamp is the newest timestamp that was summarized
+	LastTimestamp time.Time `json:"lastTimestamp"`
+	// Input is the absolute path of the file or database that was summarized
+	Input string `json:"input,omitempty"`
+	// Position is where the next run continues reading the input, the offset
+	// after the last complete line of a file or the last rowid of a database
+	Position int64 `json:"position,omitempty"`
+}
+
+// resume returns the position to continue reading the input at, 0 if the
+// state is of another input
+func (s state) resume(input string) int64 {
+	if s.Input != input {
+		return 0
+	}
+	return s.Position
+}
+
+// completeLines passes on the complete lines of a file and counts their bytes.
+// An incomplete last line, e.g. one being appended by a running monitor, is
+// left for the next run.
+type completeLines struct {
+	r       *bufio.Reader
+	pending []byte
+	offset  int64
+}
+
+func newCompleteLines(r io.Reader, offset int64) *completeLines {
+	return &completeLines{r: bufio.NewReader(r), offset: offset}
+}
+
+func (c *completeLines) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		line, err := c.r.ReadBytes('\n')
+		if err != nil {
+			return 0, err
+		}
+		c.pending = line
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	c.offset += int64(n)
+	return n, nil
+}
+
+// position returns the offset after the last line passed on
+func (c *completeLines) position() int64 {
+	return c.offset
+}
+
+// readState reads the state file, a missing file results in an empty state
+func readState(path string) (state, error) {
+	var s state
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("unable to read state %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("invalid state %s: %v", path, err)
+	}
+	return s, nil
+}
+
+func writeState(path string, s state) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("unable to write state %s: %v", path, err)
+	}
+	return nil
+}
+
+// since keeps pings newer than the last summarized timestamp and advances the
+// state to the newest kept ping. Pings arriving out of order with a timestamp
+// at or before the last summarized one are dropped, so no ping is counted twice.
+func (s *state) since(pings []*engine.Ping) []*engine.Ping {
+	last := s.LastTimestamp
+	kept := make([]*engine.Ping, 0, len(pings))
+	for _, p := range pings {
+		if !p.Timestamp.After(last) {
+			continue
+		}
+		kept = append(kept, p)
+		if p.Timestamp.After(s.LastTimestamp) {
+			s.LastTimestamp = p.Timestamp
+		}
+	}
+	return kept
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// csvLine returns a CSV record of a successful ping at the minute
+func csvLine(minute int) string {
+	return fmt.Sprintf("api;https://example.com/;Success;2024-05-01T12:%02d:00Z;200;OK;1;2;3;4;5;100;0\n", minute)
+}
+
+// summarizeWithState runs summarize on the file with the state file and
+// returns the number of measurements
+func summarizeWithState(t *testing.T, file, statePath string) int {
+	out := &bytes.Buffer{}
+	mcli := cli.New(cli.DefaultFormatter(), out, io.Discard)
+	mcli.Json = true
+	cmd := NewCommand(mcli)
+	cmd.SetArgs([]string{"-f", file, "--state", statePath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var stats []*engine.SummaryStats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid output %q: %v", out.String(), err)
+	}
+	if len(stats) == 0 {
+		return 0
+	}
+	return stats[0].NumberOfMeasurements
+}
+
+func appendFile(t *testing.T, path, s string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateContinuesAfterLastRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "monitoring.log")
+	statePath := filepath.Join(dir, "summarize.state")
+
+	appendFile(t, file, csvLine(0)+csvLine(1))
+	if n := summarizeWithState(t, file, statePath); n != 2 {
+		t.Fatalf("first run summarized %d measurements, want 2", n)
+	}
+
+	// The second run doesn't read the lines of the first one, so even a
+	// corrupted history doesn't matter. The incomplete last line is read by
+	// the next run.
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, bytes.Repeat([]byte("x"), len(b)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, file, csvLine(2)+"api;https://example.com/;Succ")
+	if n := summarizeWithState(t, file, statePath); n != 1 {
+		t.Fatalf("second run summarized %d measurements, want 1", n)
+	}
+	appendFile(t, file, "ess;2024-05-01T12:03:00Z;200;OK;1;2;3;4;5;100;0\n")
+	if n := summarizeWithState(t, file, statePath); n != 1 {
+		t.Fatalf("third run summarized %d measurements, want 1", n)
+	}
+
+	st, err := readState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Position != fi.Size() {
+		t.Errorf("Position = %d, want the file size %d", st.Position, fi.Size())
+	}
+}
+
+// A truncated file is read from the start, the timestamp still skips the
+// measurements which were summarized already
+func TestStateAfterTruncation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "monitoring.log")
+	statePath := filepath.Join(dir, "summarize.state")
+
+	appendFile(t, file, csvLine(0)+csvLine(1)+csvLine(2))
+	if n := summarizeWithState(t, file, statePath); n != 3 {
+		t.Fatalf("first run summarized %d measurements, want 3", n)
+	}
+	if err := os.WriteFile(file, []byte(csvLine(2)+csvLine(3)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n := summarizeWithState(t, file, statePath); n != 1 {
+		t.Fatalf("run after truncation summarized %d measurements, want 1", n)
+	}
+}
+
+func TestStateOfDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pings.db")
+	writeDB(t, path, DBColumns, dbRecord)
+	r, err := newSqlitePingReader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if r.position() != 1 {
+		t.Fatalf("position = %d, want 1", r.position())
+	}
+
+	writeDB(t, path, DBColumns, dbRecord)
+	if pings := readDBAfter(t, path, 1); len(pings) != 1 {
+		t.Errorf("read %d pings after rowid 1, want 1", len(pings))
+	}
+	// A position beyond the table, e.g. of a recreated table, reads all rows
+	if pings := readDBAfter(t, path, 10); len(pings) != 2 {
+		t.Errorf("read %d pings after rowid 10, want 2", len(pings))
+	}
+}
@@ -5,11 +5,16 @@
 package summarize
 
 import (
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cfichtmueller/httpmon/cli"
 	"github.com/cfichtmueller/httpmon/engine"
@@ -18,7 +23,22 @@ import (
 
 type summarizeopts struct {
 	file                 string
+	db                   string
 	ignoreInvalidRecords bool
+	excludeWindows       []string
+	timeseries           bool
+	bucket               time.Duration
+	state                string
+	prometheus           bool
+	monitors             []string
+	match                string
+	since                string
+	until                string
+	onlyFailures         bool
+	certs                bool
+	certWarn             time.Duration
+	percentiles          []float64
+	groupBy              string
 }
 
 func NewCommand(mcli *cli.Cli) *cobra.Command {
@@ -29,18 +49,26 @@ func NewCommand(mcli *cli.Cli) *cobra.Command {
 		Use:   "summarize",
 		Short: "Summarize monitoring results",
 		Run: func(cmd *cobra.Command, args []string) {
-			var r io.Reader
-			if opts.file != "" {
-				f, err := os.Open(opts.file)
+			if mcli.Influx {
+				mcli.Out.FailAndExitf("summarize doesn't support --influx\n")
+			}
+			if opts.db != "" && opts.file != "" {
+				mcli.Out.FailAndExitf("--db and --file cannot be used together\n")
+			}
+			var st state
+			if opts.state != "" {
+				var err error
+				st, err = readState(opts.state)
 				if err != nil {
 					mcli.Out.FailAndExit(err)
 				}
-				r = f
-				defer f.Close()
-			} else {
-				r = os.Stdin
 			}
-			if err := runSummarize(mcli, opts, r); err != nil {
+			in, err := openInput(mcli, opts, st)
+			if err != nil {
+				mcli.Out.FailAndExit(err)
+			}
+			defer in.close()
+			if err := runSummarize(mcli, opts, in, st); err != nil {
 				mcli.Out.FailAndExit(err)
 			}
 		},
@@ -48,25 +76,106 @@ func NewCommand(mcli *cli.Cli) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVarP(&opts.file, "file", "f", "", "Read from file")
+	flags.StringVar(&opts.db, "db", "", "Read from a SQLite database written by monitor --db")
 	flags.BoolVarP(&opts.ignoreInvalidRecords, "ignore", "i", false, "Ignore invalid records")
+	flags.StringVar(&opts.state, "state", "", "Only summarize records newer than the last run recorded in this file")
+	flags.BoolVar(&opts.prometheus, "prometheus", false, "Write statistics as Prometheus metrics")
+	flags.StringVar(&opts.groupBy, "group-by", engine.GroupByURL, "Group measurements by url, name (of the monitor) or both")
+	flags.Float64SliceVar(&opts.percentiles, "percentiles", nil, "Response time percentiles to show instead of median, P95 and P99, e.g. 50,90,95,99")
+	flags.BoolVar(&opts.certs, "certs", false, "List the certificate expiry of https endpoints, soonest first")
+	flags.DurationVar(&opts.certWarn, "cert-warn", 0, "With --certs, only list certificates expiring within this duration, e.g. 720h")
+	flags.BoolVar(&opts.timeseries, "timeseries", false, "Write statistics per endpoint and time bucket as CSV")
+	flags.DurationVar(&opts.bucket, "bucket", 5*time.Minute, "Size of the time buckets")
+	flags.StringArrayVar(&opts.excludeWindows, "exclude-window", nil, "Exclude measurements within start..end (RFC3339), can be repeated")
+	flags.StringArrayVar(&opts.monitors, "monitor", nil, "Only include measurements of this monitor, can be repeated")
+	flags.StringVar(&opts.match, "match", "", "Only include URLs matching this pattern")
+	flags.StringVar(&opts.since, "since", "", "Only include measurements at or after this time (RFC3339 or a duration ago, e.g. 24h)")
+	flags.StringVar(&opts.until, "until", "", "Only include measurements before this time (RFC3339 or a duration ago, e.g. 1h)")
+	flags.BoolVar(&opts.onlyFailures, "only-failures", false, "Only include failed measurements")
 
 	return cmd
 }
 
-func runSummarize(mcli *cli.Cli, opts summarizeopts, r io.Reader) error {
-	var reader Reader
-	if mcli.Csv {
-		cr := csv.NewReader(r)
-		cr.Comma = ';'
-		reader = cr
-	} else {
-		return fmt.Errorf("unsupported format")
+// input is the source of the pings. Files and databases can be resumed by a
+// later run with --state.
+type input struct {
+	PingReader
+	close func() error
+	// position returns where a later run continues, nil for stdin
+	position func() int64
+	// path is the absolute path of a file or database
+	path string
+}
+
+// openInput opens the database, the file or stdin. With --state, reading a
+// file or database summarized by the run which wrote the state continues where
+// that run ended, unless the file was truncated or the table recreated since.
+func openInput(mcli *cli.Cli, opts summarizeopts, st state) (*input, error) {
+	if opts.db != "" {
+		path := absPath(opts.db)
+		reader, err := newSqlitePingReader(opts.db, st.resume(path))
+		if err != nil {
+			return nil, err
+		}
+		return &input{PingReader: reader, close: reader.Close, position: reader.position, path: path}, nil
+	}
+	if opts.file == "" {
+		return &input{PingReader: NewPingReader(mcli, os.Stdin), close: func() error { return nil }}, nil
+	}
+	f, err := os.Open(opts.file)
+	if err != nil {
+		return nil, err
+	}
+	if opts.state == "" {
+		return &input{PingReader: NewPingReader(mcli, f), close: f.Close}, nil
+	}
+	path := absPath(opts.file)
+	offset := st.resume(path)
+	if fi, err := f.Stat(); err != nil || fi.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	lines := newCompleteLines(f, offset)
+	return &input{PingReader: NewPingReader(mcli, lines), close: f.Close, position: lines.position, path: path}, nil
+}
+
+// absPath returns the absolute path, or the path itself if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func runSummarize(mcli *cli.Cli, opts summarizeopts, in *input, st state) error {
+	windows, err := parseWindows(mcli.In, opts.excludeWindows)
+	if err != nil {
+		return err
 	}
-	line := 0
+	for _, p := range opts.percentiles {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("invalid percentile %g, must be greater than 0 and at most 100", p)
+		}
+	}
+	switch opts.groupBy {
+	case engine.GroupByURL, engine.GroupByName, engine.GroupByBoth:
+	default:
+		return fmt.Errorf("invalid grouping '%s', must be one of url, name, both", opts.groupBy)
+	}
+	filters, err := buildFilters(mcli.In, opts, time.Now())
+	if err != nil {
+		return err
+	}
+	if opts.timeseries && opts.bucket <= 0 {
+		return fmt.Errorf("bucket size must be positive")
+	}
+
 	pings := make([]*engine.Ping, 0)
 	for {
-		line += 1
-		record, err := reader.Read()
+		p, err := in.Next()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
@@ -76,108 +185,152 @@ func runSummarize(mcli *cli.Cli, opts summarizeopts, r io.Reader) error {
 			}
 			return err
 		}
-		if record == nil {
-			break
+		pings = append(pings, p)
+	}
+
+	if opts.state != "" {
+		pings = st.since(pings)
+	}
+
+	if len(windows) > 0 {
+		excluded := 0
+		pings, excluded = excludeWindows(pings, windows)
+		mcli.Out.Errorf("Excluded %d measurements within maintenance windows\n", excluded)
+	}
+
+	if len(filters) > 0 {
+		pings = applyFilters(pings, filters)
+		mcli.Out.Errorf("%d measurements match the filters\n", len(pings))
+	}
+
+	if err := mcli.OpenOutput(); err != nil {
+		return err
+	}
+
+	if opts.certs {
+		writeCerts(mcli, pings, opts.certWarn, time.Now())
+	} else if opts.timeseries {
+		writeTimeseries(mcli, pings, opts.bucket)
+	} else if opts.prometheus {
+		writePrometheus(mcli, engine.SummarizeBy(pings, opts.groupBy))
+	} else if mcli.Json {
+		w := mcli.Out.NewJsonWriter()
+		w.Encode(engine.SummarizeBy(pings, opts.groupBy))
+		w.Flush()
+	} else if mcli.Csv {
+		w := mcli.Out.NewCsvWriter(';')
+		WriteSummary(mcli, w, engine.SummarizeBy(pings, opts.groupBy), opts.groupBy, !mcli.Batch, opts.percentiles)
+		w.Flush()
+	} else {
+		w := mcli.Out.NewTabwriter()
+		WriteSummary(mcli, w, engine.SummarizeBy(pings, opts.groupBy), opts.groupBy, true, opts.percentiles)
+		w.Flush()
+	}
+
+	if opts.state != "" {
+		st.Input, st.Position = in.path, 0
+		if in.position != nil {
+			st.Position = in.position()
 		}
+		return writeState(opts.state, st)
+	}
+	return nil
+}
 
-		if len(record) != 13 {
-			if opts.ignoreInvalidRecords {
-				continue
-			}
-			return fmt.Errorf("invalid record on line %d", line)
+// WriteSummary writes summary statistics as rows, optionally preceded by a
+// header row. The rows start with the monitor name and/or the URL, depending
+// on how the statistics are grouped. If percentiles are given, they replace
+// the median, P95 and P99 columns.
+func WriteSummary(mcli *cli.Cli, w Writer, allStats []*engine.SummaryStats, groupBy string, header bool, percentiles []float64) {
+	withName := groupBy == engine.GroupByName || groupBy == engine.GroupByBoth
+	withURL := groupBy != engine.GroupByName
+	if header {
+		row := []string{}
+		if withName {
+			row = append(row, "MONITOR")
 		}
-		p, err := parsePing(mcli, record)
-		if err != nil {
-			if opts.ignoreInvalidRecords {
-				continue
-			}
-			return err
+		if withURL {
+			row = append(row, "URL")
 		}
-		pings = append(pings, p)
+		row = append(row, "AVAILABILITY", "AVG RT")
+		if len(percentiles) == 0 {
+			row = append(row, "MEDIAN RT", "P95 RT", "P99 RT")
+		}
+		for _, p := range percentiles {
+			row = append(row, fmt.Sprintf("P%g RT", p))
+		}
+		w.Write(append(row,
+			"LONGEST RT",
+			"CERT VALIDITY",
+			"WORST MONITOR",
+			"MEASUREMENTS",
+			"FAILED MEASUREMENTS",
+			"WARNING MEASUREMENTS",
+			"WARNING RATE",
+			"CACHE HIT RATE",
+			"FIRST MEASUREMENT",
+			"LAST MEASUREMENT",
+			"DURATION",
+			"SHORTEST RT",
+			"STDDEV RT",
+			"RT VARIATION",
+			"FAILURES BY KIND",
+		)...)
 	}
-	allStats := engine.Summarize(pings)
-	w := mcli.Out.NewTabwriter()
-	w.Write(
-		"URL",
-		"AVAILABILITY",
-		"AVG RT",
-		"MEDIAN RT",
-		"LONGEST RT",
-		"WORST MONITOR",
-		"MEASUREMENTS",
-		"FAILED MEASUREMENTS",
-	)
 	for _, stats := range allStats {
-		w.Write(
-			stats.Endpoint,
+		row := []string{}
+		if withName {
+			row = append(row, stats.Monitor)
+		}
+		if withURL {
+			row = append(row, stats.Endpoint)
+		}
+		row = append(row,
 			mcli.Formatter.FormatPercentage(stats.Availability),
 			mcli.Formatter.FormatDurationms(stats.AvgResponseTime),
-			mcli.Formatter.FormatDurationms(stats.MedianResponseTime),
+		)
+		if len(percentiles) == 0 {
+			row = append(row,
+				mcli.Formatter.FormatDurationms(stats.MedianResponseTime),
+				mcli.Formatter.FormatDurationms(stats.Percentile95ResponseTime),
+				mcli.Formatter.FormatDurationms(stats.Percentile99ResponseTime),
+			)
+		}
+		for _, p := range percentiles {
+			row = append(row, mcli.Formatter.FormatDurationms(stats.Percentile(p)))
+		}
+		w.Write(append(row,
 			mcli.Formatter.FormatDurationms(stats.LongestResponseTime),
+			mcli.Formatter.FormatDurations(stats.ShortestCertValidityTime),
 			stats.WorstMonitor,
 			mcli.Formatter.FormatInt(stats.NumberOfMeasurements),
 			mcli.Formatter.FormatInt(stats.NumberOfFailedMeasurements),
-		)
+			mcli.Formatter.FormatInt(stats.NumberOfWarningMeasurements),
+			mcli.Formatter.FormatPercentage(stats.WarningRate),
+			mcli.Formatter.FormatPercentage(stats.CacheHitRate),
+			mcli.Formatter.FormatTime(stats.FirstMeasurement),
+			mcli.Formatter.FormatTime(stats.LastMeasurement),
+			stats.MonitoringDuration,
+			mcli.Formatter.FormatDurationms(stats.ShortestResponseTime),
+			mcli.Formatter.FormatDurationms(stats.StdDevResponseTime),
+			mcli.Formatter.FormatPercentage(stats.ResponseTimeVariation),
+			formatFailuresByKind(stats.FailuresByKind),
+		)...)
 	}
-	w.Flush()
-	return nil
 }
 
-type Reader interface {
-	Read() ([]string, error)
-}
-
-func parsePing(mcli *cli.Cli, record []string) (*engine.Ping, error) {
-	timestamp, err := mcli.In.ParseTime(record[3])
-	if err != nil {
-		return nil, err
+// formatFailuresByKind formats the failed measurements per error kind sorted
+// by kind, e.g. "dns:1 timeout:2"
+func formatFailuresByKind(failures map[string]int) string {
+	kinds := slices.Sorted(maps.Keys(failures))
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = kind + ":" + strconv.Itoa(failures[kind])
 	}
-	statusCode, err := mcli.In.ParseInt(record[4])
-	if err != nil {
-		return nil, err
-	}
-	dnsTime, err := mcli.In.ParseDurationms(record[6])
-	if err != nil {
-		return nil, err
-	}
-	connectionTime, err := mcli.In.ParseDurationms(record[7])
-	if err != nil {
-		return nil, err
-	}
-	tlsTime, err := mcli.In.ParseDurationms(record[8])
-	if err != nil {
-		return nil, err
-	}
-	ttfb, err := mcli.In.ParseDurationms(record[9])
-	if err != nil {
-		return nil, err
-	}
-	downloadTime, err := mcli.In.ParseDurationms(record[10])
-	if err != nil {
-		return nil, err
-	}
-	totalResponseTime, err := mcli.In.ParseDurationms(record[11])
-	if err != nil {
-		return nil, err
-	}
-	certRemainingValidity, err := mcli.In.ParseDurations(record[12])
-	if err != nil {
-		return nil, err
-	}
-	return &engine.Ping{
-		Name:                  record[0],
-		URL:                   record[1],
-		Status:                record[2],
-		Timestamp:             timestamp,
-		StatusCode:            statusCode,
-		Message:               record[5],
-		DNSTime:               dnsTime,
-		ConnectionTime:        connectionTime,
-		TLSTime:               tlsTime,
-		TTFB:                  ttfb,
-		DownloadTime:          downloadTime,
-		TotalResponseTime:     totalResponseTime,
-		CertRemainingValidity: certRemainingValidity,
-	}, nil
+	return strings.Join(parts, " ")
+}
 
+type Writer interface {
+	Write(record ...string) error
+	Flush()
 }
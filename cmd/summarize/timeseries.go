@@ -0,0 +1,52 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+type timeseriesRow struct {
+	start time.Time
+	stats *engine.SummaryStats
+}
+
+// writeTimeseries writes one CSV row per endpoint and bucket
+func writeTimeseries(mcli *cli.Cli, pings []*engine.Ping, bucket time.Duration) {
+	rows := make([]timeseriesRow, 0)
+	for start, bucketPings := range engine.Bucket(pings, bucket) {
+		for _, stats := range engine.Summarize(bucketPings) {
+			rows = append(rows, timeseriesRow{start: start, stats: stats})
+		}
+	}
+
+	slices.SortFunc(rows, func(a, b timeseriesRow) int {
+		if c := strings.Compare(a.stats.Endpoint, b.stats.Endpoint); c != 0 {
+			return c
+		}
+		return a.start.Compare(b.start)
+	})
+
+	w := mcli.Out.NewCsvWriter(',')
+	if !mcli.Batch {
+		w.Write("url", "bucket_start", "availability", "avg_ms", "p99_ms")
+	}
+	for _, row := range rows {
+		w.Write(
+			row.stats.Endpoint,
+			mcli.Formatter.FormatTime(row.start),
+			strconv.FormatFloat(row.stats.Availability, 'f', 2, 64),
+			strconv.FormatInt(row.stats.AvgResponseTime.Milliseconds(), 10),
+			strconv.FormatInt(row.stats.Percentile99ResponseTime.Milliseconds(), 10),
+		)
+	}
+	w.Flush()
+}
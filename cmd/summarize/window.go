@@ -0,0 +1,79 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package summarize
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfichtmueller/httpmon/cli"
+	"github.com/cfichtmueller/httpmon/engine"
+)
+
+// window is a time range, including start and excluding end
+type window struct {
+	start time.Time
+	end   time.Time
+}
+
+func (w window) contains(t time.Time) bool {
+	return !t.Before(w.start) && t.Before(w.end)
+}
+
+// parseWindows parses windows in the form start..end and merges overlapping ones
+func parseWindows(in *cli.In, specs []string) ([]window, error) {
+	windows := make([]window, 0, len(specs))
+	for _, spec := range specs {
+		start, end, ok := strings.Cut(spec, "..")
+		if !ok {
+			return nil, fmt.Errorf("invalid window '%s', expected start..end", spec)
+		}
+		s, err := in.ParseTime(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window start '%s': %v", start, err)
+		}
+		e, err := in.ParseTime(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window end '%s': %v", end, err)
+		}
+		if !e.After(s) {
+			return nil, fmt.Errorf("invalid window '%s', end must be after start", spec)
+		}
+		windows = append(windows, window{start: s, end: e})
+	}
+
+	slices.SortFunc(windows, func(a, b window) int {
+		return a.start.Compare(b.start)
+	})
+
+	merged := make([]window, 0, len(windows))
+	for _, w := range windows {
+		if n := len(merged); n > 0 && !w.start.After(merged[n-1].end) {
+			if w.end.After(merged[n-1].end) {
+				merged[n-1].end = w.end
+			}
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged, nil
+}
+
+// excludeWindows drops pings within any of the windows and returns the remaining pings
+// and the number of excluded pings
+func excludeWindows(pings []*engine.Ping, windows []window) ([]*engine.Ping, int) {
+	if len(windows) == 0 {
+		return pings, 0
+	}
+	kept := make([]*engine.Ping, 0, len(pings))
+	for _, p := range pings {
+		if !slices.ContainsFunc(windows, func(w window) bool { return w.contains(p.Timestamp) }) {
+			kept = append(kept, p)
+		}
+	}
+	return kept, len(pings) - len(kept)
+}
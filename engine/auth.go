@@ -0,0 +1,59 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// TokenSource provides bearer tokens for the Authorization header
+type TokenSource interface {
+	// Token returns the current token, obtaining a new one if needed
+	Token() (string, error)
+	// Invalidate drops the token after the endpoint rejected it
+	Invalidate(token string)
+}
+
+// executeAuthenticatedAttempt executes a single attempt with a bearer token
+// from the engine's Tokens. If the endpoint responds with 401, the token is
+// invalidated and the request is repeated once with a fresh token before the
+// attempt counts as failed.
+func (e *Engine) executeAuthenticatedAttempt(ctx context.Context, monitor *Monitor) (*Ping, error) {
+	if e.Tokens == nil {
+		return e.executeAttempt(ctx, monitor)
+	}
+	var ping *Ping
+	var err error
+	for i := 0; i < 2; i++ {
+		token, tokenErr := e.Tokens.Token()
+		if tokenErr != nil {
+			return &Ping{
+				Name:          monitor.Name,
+				URL:           monitor.pingURL(),
+				Status:        StatusFailed,
+				Timestamp:     e.now(),
+				Message:       fmt.Sprintf("Error obtaining token: %v", tokenErr),
+				FailureReason: FailureAuth,
+				ErrorKind:     ErrorKindOther,
+			}, &PingError{Reason: FailureAuth, Err: tokenErr}
+		}
+		m := *monitor
+		m.Headers = maps.Clone(m.Headers)
+		if m.Headers == nil {
+			m.Headers = make(map[string]string)
+		}
+		m.Headers["Authorization"] = "Bearer " + token
+		ping, err = e.executeAttempt(ctx, &m)
+		if ping.StatusCode != http.StatusUnauthorized {
+			break
+		}
+		e.log(1, monitor, "token rejected with %d, refreshing", ping.StatusCode)
+		e.Tokens.Invalidate(token)
+	}
+	return ping, err
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultMaxBodySize limits how much of a response body is read if the
+// monitor doesn't set MaxBodySize
+const DefaultMaxBodySize = 10 << 20
+
+// needsBody reports whether a check needs the response body
+func (m *Monitor) needsBody() bool {
+	return m.JSONSchema != nil || m.CaptureResponse || m.expectsBody()
+}
+
+func (m *Monitor) maxBodySize() int64 {
+	if m.MaxBodySize > 0 {
+		return m.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readBody drains the response body up to the monitor's maximum body size and
+// returns the number of bytes read. The body is only kept if a check needs it.
+// Event streams are only read until the first event.
+func readBody(monitor *Monitor, resp *http.Response) ([]byte, int64, error) {
+	r := &countingReader{r: io.LimitReader(resp.Body, monitor.maxBodySize())}
+	if isMediaType(resp.Header.Get("Content-Type"), "text/event-stream") {
+		err := readEventStream(monitor, r, resp.Body)
+		return nil, r.n, err
+	}
+	if !monitor.needsBody() {
+		// io.Discard reads into pooled buffers
+		_, err := io.Copy(io.Discard, r)
+		return nil, r.n, err
+	}
+	b, err := io.ReadAll(r)
+	return b, r.n, err
+}
+
+// readEventStream reads an event stream until the first complete event. If
+// StreamTimeout is set, the stream is closed if no event arrived in time.
+func readEventStream(monitor *Monitor, stream io.Reader, body io.Closer) error {
+	var timer *time.Timer
+	if monitor.StreamTimeout > 0 {
+		timer = time.AfterFunc(monitor.StreamTimeout, func() {
+			body.Close()
+		})
+	}
+
+	r := bufio.NewReader(stream)
+	data := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if timer != nil && !timer.Stop() {
+				return fmt.Errorf("no event received within %s", monitor.StreamTimeout)
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" && data {
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+		data = data || line != ""
+	}
+}
+
+// validateSchema validates a JSON body against a schema and returns the first validation error
+func validateSchema(schema *jsonschema.Schema, body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	err := schema.Validate(v)
+	var ve *jsonschema.ValidationError
+	if errors.As(err, &ve) {
+		for len(ve.Causes) > 0 {
+			ve = ve.Causes[0]
+		}
+		return fmt.Errorf("%s: %s", ve.InstanceLocation, ve.Message)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Cache statuses reported in Ping.CacheStatus
+const (
+	CacheHit     = "hit"
+	CacheMiss    = "miss"
+	CacheUnknown = "unknown"
+)
+
+// cacheStatus detects from common cache headers whether a response was served
+// from a cache. The standard Cache-Status header is preferred over the vendor
+// specific headers, Age is only used if none of them is present.
+func cacheStatus(header http.Header) string {
+	if v := header.Get("Cache-Status"); v != "" {
+		// e.g. "ExampleCache; hit" or "ExampleCache; fwd=uri-miss"
+		for _, param := range strings.Split(v, ";") {
+			if strings.EqualFold(strings.TrimSpace(param), "hit") {
+				return CacheHit
+			}
+		}
+		return CacheMiss
+	}
+	if v := header.Get("CF-Cache-Status"); v != "" {
+		switch strings.ToUpper(v) {
+		case "HIT", "STALE", "UPDATING", "REVALIDATED":
+			return CacheHit
+		default:
+			return CacheMiss
+		}
+	}
+	if v := strings.ToUpper(header.Get("X-Cache")); v != "" {
+		// CDNs with several layers list each, e.g. "MISS, HIT"
+		if strings.Contains(v, "HIT") {
+			return CacheHit
+		}
+		if strings.Contains(v, "MISS") {
+			return CacheMiss
+		}
+	}
+	if v := header.Get("Age"); v != "" {
+		if age, err := strconv.Atoi(v); err == nil {
+			if age > 0 {
+				return CacheHit
+			}
+			return CacheMiss
+		}
+	}
+	return CacheUnknown
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// checkFailure describes why a response didn't pass a check
+type checkFailure struct {
+	reason  string
+	message string
+}
+
+// checkResponse runs the monitor's checks against a response in order and
+// returns the first failure, or nil if all checks passed
+func checkResponse(monitor *Monitor, resp *http.Response, body []byte) *checkFailure {
+	if monitor.FailOnRedirect && isRedirect(resp) {
+		return &checkFailure{
+			reason:  FailureUnexpectedRedirect,
+			message: fmt.Sprintf("Unexpected redirect to %s", redirectTarget(resp)),
+		}
+	}
+
+	if !isStatusCodeAccepted(resp.StatusCode, monitor.AcceptedStatusCodes) {
+		return &checkFailure{
+			reason:  FailureStatusCode,
+			message: http.StatusText(resp.StatusCode),
+		}
+	}
+
+	if monitor.RequireCertInfo && resp.Request.URL.Scheme == "https" && (resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0) {
+		return &checkFailure{
+			reason:  FailureCertInfo,
+			message: "No certificate information available",
+		}
+	}
+
+	finalURL := resp.Request.URL.String()
+	if monitor.ExpectFinalURL != nil && !monitor.ExpectFinalURL.MatchString(finalURL) {
+		return &checkFailure{
+			reason:  FailureRedirectTarget,
+			message: fmt.Sprintf("Final URL %s does not match %s", finalURL, monitor.ExpectFinalURL),
+		}
+	}
+
+	if monitor.Preflight != nil {
+		if f := checkPreflight(monitor.Preflight, resp.Header); f != nil {
+			return f
+		}
+	}
+
+	if monitor.ExpectCache != "" {
+		if status := cacheStatus(resp.Header); status != monitor.ExpectCache {
+			return &checkFailure{
+				reason:  FailureCache,
+				message: fmt.Sprintf("Cache status %s does not match %s", status, monitor.ExpectCache),
+			}
+		}
+	}
+
+	if monitor.ExpectContentType != "" {
+		contentType := resp.Header.Get("Content-Type")
+		if !isMediaType(contentType, monitor.ExpectContentType) {
+			return &checkFailure{
+				reason:  FailureContentType,
+				message: fmt.Sprintf("Content-Type '%s' does not match %s", contentType, monitor.ExpectContentType),
+			}
+		}
+	}
+
+	if monitor.JSONSchema != nil {
+		if err := validateSchema(monitor.JSONSchema, body); err != nil {
+			return &checkFailure{
+				reason:  FailureSchema,
+				message: fmt.Sprintf("Schema validation failed: %v", err),
+			}
+		}
+	}
+
+	if monitor.ExpectBody != "" && !bytes.Contains(body, []byte(monitor.ExpectBody)) {
+		return &checkFailure{
+			reason:  FailureBody,
+			message: fmt.Sprintf("Body does not contain '%s'", monitor.ExpectBody),
+		}
+	}
+
+	if monitor.ExpectBodyRegex != nil && !monitor.ExpectBodyRegex.Match(body) {
+		return &checkFailure{
+			reason:  FailureBody,
+			message: fmt.Sprintf("Body does not match %s", monitor.ExpectBodyRegex),
+		}
+	}
+
+	return nil
+}
+
+// expectsBody reports whether the monitor checks the content of the body
+func (m *Monitor) expectsBody() bool {
+	return m.ExpectBody != "" || m.ExpectBodyRegex != nil
+}
+
+// isMediaType compares the media type of a Content-Type header, ignoring parameters like charset
+func isMediaType(contentType, expected string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if e, _, err := mime.ParseMediaType(expected); err == nil {
+		expected = e
+	}
+	return strings.EqualFold(mediaType, expected)
+}
+
+// checkPreflight checks that a preflight response allows the origin and method
+func checkPreflight(preflight *Preflight, header http.Header) *checkFailure {
+	allowedOrigin := header.Get("Access-Control-Allow-Origin")
+	if allowedOrigin != "*" && allowedOrigin != preflight.Origin {
+		return &checkFailure{
+			reason:  FailureCORS,
+			message: fmt.Sprintf("Origin %s is not allowed (Access-Control-Allow-Origin: '%s')", preflight.Origin, allowedOrigin),
+		}
+	}
+	allowedMethods := header.Get("Access-Control-Allow-Methods")
+	for _, m := range strings.Split(allowedMethods, ",") {
+		m = strings.TrimSpace(m)
+		if m == "*" || strings.EqualFold(m, preflight.Method) {
+			return nil
+		}
+	}
+	return &checkFailure{
+		reason:  FailureCORS,
+		message: fmt.Sprintf("Method %s is not allowed (Access-Control-Allow-Methods: '%s')", preflight.Method, allowedMethods),
+	}
+}
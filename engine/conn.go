@@ -0,0 +1,68 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// connTrace records the connection attempts of a request. With Happy Eyeballs
+// several attempts run concurrently and losing attempts may even complete
+// after the request, so all fields are guarded by the mutex.
+type connTrace struct {
+	mu         sync.Mutex
+	starts     map[string]time.Time
+	connected  bool
+	duration   time.Duration
+	remoteAddr string
+	err        error
+}
+
+// start records the start of an attempt to connect to addr
+func (c *connTrace) start(addr string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.starts == nil {
+		c.starts = make(map[string]time.Time)
+	}
+	c.starts[addr] = t
+}
+
+// done records the end of an attempt and returns when it started. The first
+// successful attempt is the connection of the request, of failed attempts the
+// last error is kept.
+func (c *connTrace) done(addr string, t time.Time, err error) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start, ok := c.starts[addr]
+	if !ok {
+		start = t
+	}
+	if err != nil {
+		c.err = err
+	} else if !c.connected {
+		c.connected = true
+		c.duration = t.Sub(start)
+		c.remoteAddr = addr
+	}
+	return start
+}
+
+// attempted reports whether a connection attempt was started. Attempts still
+// in progress when the request was aborted have no result.
+func (c *connTrace) attempted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.starts) > 0
+}
+
+// result returns whether a connection was established, how long it took, its
+// remote address and the error of the last failed attempt
+func (c *connTrace) result() (connected bool, duration time.Duration, remoteAddr string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected, c.duration, c.remoteAddr, c.err
+}
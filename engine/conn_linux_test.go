@@ -0,0 +1,63 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fullListener returns the address of a listener whose accept queue is full,
+// so further connection attempts hang until they time out
+func fullListener(t *testing.T) string {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", sa.(*syscall.SockaddrInet4).Port)
+	// Fill the queue with a connection which is never accepted
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return addr
+}
+
+// A connect timeout fails in the connect phase, the error kind records the
+// timeout. That holds whether the dialer or the response timeout expires.
+func TestConnectTimeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		connectTimeout  time.Duration
+		responseTimeout time.Duration
+	}{
+		{"connect timeout", 100 * time.Millisecond, 5 * time.Second},
+		{"response timeout while connecting", 5 * time.Second, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		monitor := testMonitor("http://" + fullListener(t) + "/")
+		monitor.ConnectTimeout = tt.connectTimeout
+		monitor.ResponseTimeout = tt.responseTimeout
+		p := ExecutePing(monitor)
+		if p.FailureReason != FailureConnect || p.ErrorKind != ErrorKindTimeout {
+			t.Errorf("%s: FailureReason, ErrorKind = %q, %q, want %q, %q (%s)", tt.name, p.FailureReason, p.ErrorKind, FailureConnect, ErrorKindTimeout, p.Message)
+		}
+	}
+}
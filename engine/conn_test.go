@@ -0,0 +1,44 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race: Happy Eyeballs reports attempts concurrently
+func TestConnTraceConcurrentAttempts(t *testing.T) {
+	c := &connTrace{}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	refused := errors.New("connection refused")
+
+	c.start("[::1]:443", start)
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.done("[::1]:443", start.Add(300*time.Millisecond), refused)
+	}()
+	go func() {
+		defer wg.Done()
+		c.start("127.0.0.1:443", start.Add(250*time.Millisecond))
+		c.done("127.0.0.1:443", start.Add(260*time.Millisecond), nil)
+	}()
+	wg.Wait()
+
+	connected, duration, remoteAddr, err := c.result()
+	if !connected || remoteAddr != "127.0.0.1:443" {
+		t.Errorf("connected = %t to %s, want a connection to 127.0.0.1:443", connected, remoteAddr)
+	}
+	if duration != 10*time.Millisecond {
+		t.Errorf("duration = %s, want the 10ms of the winning attempt", duration)
+	}
+	if err != refused {
+		t.Errorf("err = %v, want %v", err, refused)
+	}
+}
@@ -5,13 +5,22 @@
 package engine
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 // Monitor defines what and how to monitor
@@ -19,154 +28,663 @@ type Monitor struct {
 	Name                string
 	URL                 string
 	Retries             int
-	RetryInterval       int
+	RetryInterval       int // seconds
 	ConnectTimeout      time.Duration
 	ResponseTimeout     time.Duration
 	MaxRedirects        int
 	AcceptedStatusCodes []int
 	HTTPMethod          string
 	Headers             map[string]string
+	Body                []byte // sent with the request, nil for no body
+	ContentType         string // Content-Type of the body
+	// AcceptEncoding, if set, is sent as Accept-Encoding header and disables
+	// transparent decompression, so the body is measured as sent
+	AcceptEncoding string
+	// RequestIDHeader, if set, is the header to send a unique ID per ping in
+	RequestIDHeader string
+	// Preflight, if set, sends a CORS preflight request instead and checks
+	// that the origin and method are allowed
+	Preflight *Preflight
+	// ExpectCache is the cache status the response must have, e.g. hit
+	ExpectCache string
+	// FailOnRedirect fails pings that are redirected instead of following
+	FailOnRedirect bool
+	// NoFollow reports redirect responses instead of following them. Their
+	// status code is checked like any other, MaxRedirects doesn't apply.
+	NoFollow bool
+	// TLSConfig configures TLS connections, e.g. to trust a custom CA
+	TLSConfig *tls.Config
+	// Labels are copied to the monitor's pings
+	Labels map[string]string
+	// IdleTimeout, if set, aborts reading the body when no bytes arrived for
+	// that long. The response timeout then only applies until the headers.
+	IdleTimeout time.Duration
+	// MaxBodySize limits how much of a response body is read, DefaultMaxBodySize
+	// applies if it is zero
+	MaxBodySize int64
+	// RetryOnAssertion also retries pings whose response failed a check
+	RetryOnAssertion bool
+	// RetryBudget, if set, limits the time spent retrying
+	RetryBudget time.Duration
+	// Waterfall records the absolute time of each trace event
+	Waterfall bool
+	// CaptureResponse keeps the response headers and body in the Ping
+	CaptureResponse bool
+	// StreamTimeout limits how long to wait for the first event of an
+	// event stream. The response timeout applies if it is zero.
+	StreamTimeout time.Duration
+	// HTTP2 attempts to negotiate HTTP/2 over TLS. Without it requests use
+	// HTTP/1.1.
+	HTTP2 bool
+	// KeepAlive reuses connections between pings. Pings over a reused
+	// connection don't include DNS, connection and TLS handshake times.
+	KeepAlive bool
+	// LocalAddr, if set, is the source address of connections
+	LocalAddr net.IP
+	// Proxy, if set, is the HTTP, HTTPS or SOCKS5 proxy to send requests
+	// through. Otherwise the proxy is taken from the environment.
+	Proxy *url.URL
+	// Resolver, if set, is the address of the DNS server to resolve host
+	// names with instead of the system's resolver, e.g. 1.1.1.1:53
+	Resolver string
+	// MaxConnsPerHost limits the connections per host, zero means no limit
+	MaxConnsPerHost int
+	// MaxIdleConnsPerHost limits the idle connections kept per host if
+	// KeepAlive is set. Zero uses the default of the http package.
+	MaxIdleConnsPerHost int
+	// StripQuery removes the query string from the URL reported in the Ping.
+	// It only affects the grouping key, the request still uses the full URL.
+	StripQuery bool
+	// ExpectFinalURL, if set, must match the URL the request ended up at
+	// after following redirects
+	ExpectFinalURL *regexp.Regexp
+	// CertWarn, if set, reports pings as warnings if the certificate
+	// expires within that time
+	CertWarn time.Duration
+	// RequireCertInfo fails https pings without certificate information
+	RequireCertInfo bool
+	// NoCrossHostRedirect fails pings that are redirected to a different host
+	NoCrossHostRedirect bool
+	// ExpectContentType, if set, must match the media type of the response
+	ExpectContentType string
+	// ExpectBody, if set, must be contained in the response body
+	ExpectBody string
+	// ExpectBodyRegex, if set, must match the response body
+	ExpectBodyRegex *regexp.Regexp
+	// JSONSchema, if set, is used to validate the response body
+	JSONSchema *jsonschema.Schema
+}
+
+// Preflight describes a CORS preflight request
+type Preflight struct {
+	Origin string
+	Method string
 }
 
 // Ping is the result of a monitoring event
 type Ping struct {
 	Name                  string
 	URL                   string
+	Labels                map[string]string `json:",omitempty"`
+	RequestID             string
 	Status                string
 	Timestamp             time.Time
 	StatusCode            int
 	Message               string
 	DNSTime               time.Duration
+	ResolvedAddrs         []string
+	Resolver              string `json:",omitempty"` // DNS server used, empty for the system's resolver
+	RemoteAddr            string // address the request was sent to, empty if the connection was reused
 	ConnectionTime        time.Duration
 	TLSTime               time.Duration
 	TTFB                  time.Duration
+	Received1xx           bool          // an informational response was received before the final one
+	EarlyHintsTime        time.Duration // time until 103 Early Hints, 0 if none were received
 	DownloadTime          time.Duration
+	ResponseSize          int64 // bytes of the body that were read
 	TotalResponseTime     time.Duration
 	CertRemainingValidity time.Duration
+	CertChecked           bool // false if no certificate information was available
+	CertExpiring          bool // the certificate expires within the monitor's CertWarn
+	TLSVersion            string
+	Protocol              string // protocol of the response, e.g. HTTP/2.0
+	CertIssuer            string
+	FinalURL              string
+	Redirects             []Redirect `json:",omitempty"` // redirects that were followed, in order
+	RedirectLocation      string
+	ContentType           string
+	ContentEncoding       string
+	BodyMatched           bool // the body matched the monitor's body expectations, false if they weren't checked
+	CacheStatus           string
+	ServerClockSkew       *time.Duration // server Date minus local time, nil without a valid Date header
+	FailureReason         string
+	ErrorKind             string `json:",omitempty"` // classifies requests which failed without a complete response
+	Attempts              int
+	RetryBudgetExhausted  bool             // retries were stopped by the retry budget
+	RetriedOnAssertion    bool             // an earlier attempt was retried because of a failed check
+	Waterfall             []WaterfallEvent `json:",omitempty"` // only set if the monitor records the waterfall
+	ResponseHeader        http.Header      `json:"-"`          // only set if the monitor captures the response
+	ResponseBody          []byte           `json:"-"`          // only set if the monitor captures the response
+}
+
+// Values of Ping.Status
+const (
+	StatusSuccess = "Success"
+	StatusWarning = "Warning"
+	StatusFailed  = "Failed"
+)
+
+// Failure reasons reported in Ping.FailureReason
+const (
+	FailureStatusCode     = "status-code"
+	FailureRedirectTarget = "redirect-target"
+	FailureContentType    = "content-type"
+	FailureSchema         = "schema"
+	FailureDownload       = "download"
+	FailureCertInfo       = "cert-info"
+	FailureCORS           = "cors"
+
+	FailureRequest            = "request"
+	FailurePlaintextOnTLSPort = "plaintext-on-tls-port"
+	FailureCrossHostRedirect  = "cross-host-redirect"
+	FailureConnect            = "connect"
+	FailureDNS                = "dns"
+	FailureTimeout            = "timeout"
+	FailureTLS                = "tls"
+	FailureIdleTimeout        = "idle-timeout"
+	FailureUnexpectedRedirect = "unexpected-redirect"
+	FailureCache              = "cache"
+	FailureBody               = "body"
+	FailureAuth               = "auth"
+)
+
+// Values of Ping.ErrorKind. Failures of the response itself, like an
+// unexpected status code, have no error kind.
+const (
+	ErrorKindTimeout    = "timeout"
+	ErrorKindDNS        = "dns"
+	ErrorKindConnection = "connection"
+	ErrorKindTLS        = "tls"
+	ErrorKindOther      = "other"
+)
+
+// Clock provides the current time and waits between retries. Sleep returns
+// early when the context is done.
+type Clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration)
+}
+
+// Engine executes pings. The zero value uses shared transports built from the
+// monitor's settings and the system clock.
+type Engine struct {
+	// Transport, if set, is used for all requests
+	Transport http.RoundTripper
+	// Clock, if set, provides timestamps, timings and the waits between retries
+	Clock Clock
+	// Logger, if set, receives the events of each request
+	Logger Logger
+	// Tokens, if set, provides a bearer token for each attempt. A token
+	// rejected with 401 is refreshed once within the attempt.
+	Tokens TokenSource
 }
 
-// executePing takes a Monitor and produces a Ping
+var defaultEngine = &Engine{}
+
+// ExecutePing takes a Monitor and produces a Ping using the default engine
 func ExecutePing(monitor *Monitor) *Ping {
+	return defaultEngine.ExecutePing(monitor)
+}
+
+// ExecutePingContext is like ExecutePing but aborts the request and retries
+// when the context is done
+func ExecutePingContext(ctx context.Context, monitor *Monitor) *Ping {
+	return defaultEngine.ExecutePingContext(ctx, monitor)
+}
+
+// ExecutePingE is like ExecutePing but also returns a *PingError if no
+// response was received
+func ExecutePingE(monitor *Monitor) (*Ping, error) {
+	return defaultEngine.ExecutePingE(monitor)
+}
+
+// executeAttempt executes a single request of a ping. The error is set if the
+// request couldn't be created or executed.
+func (e *Engine) executeAttempt(ctx context.Context, monitor *Monitor) (*Ping, error) {
 	// Timing variables
-	var dnsStart, connStart, tlsStart, firstByteTime time.Time
-	var dnsDuration, connDuration, tlsDuration, downloadTime time.Duration
+	var dnsStart, tlsStart, firstByteTime, earlyHintsTime time.Time
+	var dnsDuration, tlsDuration, downloadTime time.Duration
 	var certRemainingValidity time.Duration
-
-	// Create a custom HTTP transport with separate connect and response timeouts
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout: monitor.ConnectTimeout,
-		}).DialContext,
-		TLSHandshakeTimeout: monitor.ConnectTimeout, // Apply the connect timeout to the TLS handshake
-	}
+	var tlsVersion, certIssuer string
+	var certChecked bool
+	var resolvedAddrs []string
+	var received1xx bool
+	var tlsErr error
+	conns := &connTrace{}
+	var redirects []Redirect
+	var start time.Time
+	events := &waterfall{enabled: monitor.Waterfall}
 
 	// Create a custom HTTP client
 	client := &http.Client{
-		Transport: transport,
+		Transport: e.transport(monitor),
 		Timeout:   monitor.ResponseTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			err := monitor.checkRedirect(req, via)
+			if err != http.ErrUseLastResponse {
+				redirects = append(redirects, Redirect{URL: via[len(via)-1].URL.String(), StatusCode: req.Response.StatusCode})
+			}
+			if err == nil {
+				e.log(1, monitor, "following %d redirect to %s", req.Response.StatusCode, req.URL)
+			}
+			return err
+		},
+	}
+	if monitor.IdleTimeout > 0 {
+		// The transport applies the response timeout to the headers only
+		client.Timeout = 0
 	}
 
 	// Create an HTTP request with the appropriate method and headers
-	req, err := http.NewRequest(monitor.HTTPMethod, monitor.URL, nil)
+	method := monitor.HTTPMethod
+	var reqBody io.Reader
+	if monitor.Preflight != nil {
+		method = http.MethodOptions
+	} else if monitor.Body != nil {
+		reqBody = bytes.NewReader(monitor.Body)
+	}
+	req, err := http.NewRequest(method, monitor.URL, reqBody)
 	if err != nil {
 		return &Ping{
-			Name:      monitor.Name,
-			URL:       monitor.URL,
-			Status:    "Failed",
-			Timestamp: time.Now(),
-			Message:   fmt.Sprintf("Error creating request: %v", err),
-		}
+			Name:          monitor.Name,
+			URL:           monitor.pingURL(),
+			Status:        StatusFailed,
+			Timestamp:     e.now(),
+			Message:       fmt.Sprintf("Error creating request: %v", err),
+			FailureReason: FailureRequest,
+			ErrorKind:     ErrorKindOther,
+		}, &PingError{Reason: FailureRequest, Err: err}
 	}
 
 	for key, value := range monitor.Headers {
 		req.Header.Set(key, value)
 	}
+	if monitor.AcceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", monitor.AcceptEncoding)
+	}
+	if reqBody != nil && monitor.ContentType != "" {
+		req.Header.Set("Content-Type", monitor.ContentType)
+	}
+	requestID := ""
+	if monitor.RequestIDHeader != "" {
+		requestID = newRequestID()
+		req.Header.Set(monitor.RequestIDHeader, requestID)
+	}
+	if monitor.Preflight != nil {
+		req.Header.Set("Origin", monitor.Preflight.Origin)
+		req.Header.Set("Access-Control-Request-Method", monitor.Preflight.Method)
+	}
 
 	// Add trace to measure DNS, connection, TLS handshake times, and TTFB
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			dnsStart = time.Now()
+			dnsStart = e.now()
+			events.add(EventDNSStart, dnsStart)
+			e.log(2, monitor, "resolving %s", info.Host)
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			dnsDuration = time.Since(dnsStart)
+			dnsDuration = e.since(dnsStart)
+			events.add(EventDNSDone, dnsStart.Add(dnsDuration))
+			for _, addr := range info.Addrs {
+				resolvedAddrs = append(resolvedAddrs, addr.String())
+			}
+			if info.Err != nil {
+				e.log(1, monitor, "resolving failed after %s: %v", dnsDuration, info.Err)
+			} else {
+				e.log(1, monitor, "resolved to %s in %s", strings.Join(resolvedAddrs, ", "), dnsDuration)
+			}
 		},
 		ConnectStart: func(network, addr string) {
-			connStart = time.Now()
+			connStart := e.now()
+			conns.start(addr, connStart)
+			events.add(EventConnectStart, connStart)
+			e.log(2, monitor, "connecting to %s", addr)
 		},
 		ConnectDone: func(network, addr string, err error) {
-			connDuration = time.Since(connStart)
+			connDone := e.now()
+			connStart := conns.done(addr, connDone, err)
+			events.add(EventConnectDone, connDone)
+			if err != nil {
+				e.log(1, monitor, "connecting to %s failed after %s: %v", addr, connDone.Sub(connStart), err)
+			} else {
+				e.log(1, monitor, "connected to %s in %s", addr, connDone.Sub(connStart))
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				e.log(1, monitor, "reusing connection to %s", info.Conn.RemoteAddr())
+			}
 		},
 		TLSHandshakeStart: func() {
-			tlsStart = time.Now()
+			tlsStart = e.now()
+			events.add(EventTLSStart, tlsStart)
+			e.log(2, monitor, "starting TLS handshake")
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			tlsDuration = time.Since(tlsStart)
+			tlsDuration = e.since(tlsStart)
+			events.add(EventTLSDone, tlsStart.Add(tlsDuration))
+			tlsErr = err
+			if err != nil {
+				e.log(1, monitor, "TLS handshake failed after %s: %v", tlsDuration, err)
+			} else {
+				e.log(1, monitor, "negotiated %s with %s in %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), tlsDuration)
+			}
 			if err == nil {
 				// If TLS handshake succeeded, check the certificate validity
+				tlsVersion = tls.VersionName(state.Version)
 				if len(state.PeerCertificates) > 0 {
 					cert := state.PeerCertificates[0]
-					remaining := time.Until(cert.NotAfter)
+					remaining := cert.NotAfter.Sub(e.now())
 					certRemainingValidity = remaining
+					certIssuer = cert.Issuer.String()
+					certChecked = true
 				}
 			}
 		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			e.log(2, monitor, "sent request")
+		},
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			e.log(2, monitor, "received %d", code)
+			received1xx = true
+			if code == http.StatusEarlyHints && earlyHintsTime.IsZero() {
+				earlyHintsTime = e.now()
+			}
+			return nil
+		},
 		GotFirstResponseByte: func() {
-			firstByteTime = time.Now()
+			firstByteTime = e.now()
+			events.add(EventFirstByte, firstByteTime)
+			e.log(2, monitor, "received first byte after %s", firstByteTime.Sub(start))
 		},
 	}
 
 	// Associate the trace with the request's context
-	req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
 
 	// Record the start time of the request
-	start := time.Now()
+	start = e.now()
+	events.add(EventStart, start)
+	e.log(1, monitor, "sending %s %s", method, monitor.URL)
 
 	// Execute the request
 	resp, err := client.Do(req)
+	responded := e.now()
+	connected, connDuration, remoteAddr, connectErr := conns.result()
 	if err != nil {
-		return &Ping{
-			Name:      monitor.Name,
-			URL:       monitor.URL,
-			Status:    "Failed",
-			Timestamp: time.Now(),
-			Message:   fmt.Sprintf("Error executing request: %v", err),
+		e.log(1, monitor, "request failed: %v", err)
+		message := fmt.Sprintf("Error executing request: %v", err)
+		failureReason := ""
+		errorKind := ErrorKindOther
+		redirectLocation := ""
+		var redirectErr *redirectError
+		if errors.As(err, &redirectErr) {
+			// A redirect rejected by the monitor is a failure of the response
+			failureReason = redirectErr.reason
+			errorKind = ""
+			redirectLocation = redirectErr.target
+		} else if isPlaintextOnTLSPort(err) {
+			message = fmt.Sprintf("Server answered in plaintext on a TLS port: %v", err)
+			failureReason = FailurePlaintextOnTLSPort
+			errorKind = ErrorKindTLS
+		} else if isDNSError(err) {
+			failureReason = FailureDNS
+			errorKind = ErrorKindDNS
+		} else if !connected && (connectErr != nil || conns.attempted()) {
+			failureReason = FailureConnect
+			errorKind = ErrorKindConnection
+		} else if tlsErr != nil {
+			failureReason = FailureTLS
+			errorKind = ErrorKindTLS
+		} else if isTimeout(err) {
+			failureReason = FailureTimeout
+			errorKind = ErrorKindTimeout
 		}
+		// The failure reason names the phase that failed, the error kind
+		// whether it failed because a timeout expired
+		if errorKind != "" && isTimeout(err) {
+			errorKind = ErrorKindTimeout
+		}
+		// Keep the certificate information if the TLS handshake completed
+		return &Ping{
+			Name:                  monitor.Name,
+			URL:                   monitor.pingURL(),
+			RequestID:             requestID,
+			Status:                StatusFailed,
+			Timestamp:             e.now(),
+			Message:               message,
+			ResolvedAddrs:         resolvedAddrs,
+			Resolver:              monitor.Resolver,
+			RemoteAddr:            remoteAddr,
+			CertRemainingValidity: certRemainingValidity,
+			CertChecked:           certChecked,
+			TLSVersion:            tlsVersion,
+			CertIssuer:            certIssuer,
+			Redirects:             redirects,
+			RedirectLocation:      redirectLocation,
+			FailureReason:         failureReason,
+			ErrorKind:             errorKind,
+			Waterfall:             events.list(),
+		}, &PingError{Reason: failureReason, Err: err}
+	}
+	if monitor.IdleTimeout > 0 {
+		resp.Body = newIdleReader(resp.Body, monitor.IdleTimeout)
 	}
 	defer resp.Body.Close()
 
-	// Calculate TTFB
+	// Calculate TTFB. A Transport other than the http.Transport doesn't run
+	// the trace, the response then counts from the time it was returned.
+	sawFirstByte := !firstByteTime.IsZero()
+	if !sawFirstByte {
+		firstByteTime = responded
+		events.add(EventFirstByte, firstByteTime)
+	}
 	ttfb := firstByteTime.Sub(start)
+	var earlyHints time.Duration
+	if !earlyHintsTime.IsZero() {
+		earlyHints = earlyHintsTime.Sub(start)
+	}
 
 	// Measure download time (after the first byte)
-	downloadStart := time.Now()
-	_, _ = http.MaxBytesReader(nil, resp.Body, 10<<20).Read(make([]byte, 10<<20)) // Limiting to 10MB read for example
-	downloadTime = time.Since(downloadStart)
+	downloadStart := e.now()
+	body, responseSize, readErr := readBody(monitor, resp)
+	downloadTime = e.since(downloadStart)
+	events.add(EventBodyDone, downloadStart.Add(downloadTime))
+	e.log(1, monitor, "received %s and %d bytes in %s", resp.Status, responseSize, downloadTime)
+	if readErr != nil {
+		e.log(1, monitor, "reading the response failed: %v", readErr)
+	}
 
 	// Calculate total response time
-	totalDuration := time.Since(start)
+	totalDuration := e.since(start)
+
+	// Run the checks against the response
+	status := StatusSuccess
+	message := http.StatusText(resp.StatusCode)
+	failureReason := ""
+	errorKind := ""
+	if errors.Is(readErr, errIdleTimeout) {
+		status = StatusFailed
+		failureReason = FailureIdleTimeout
+		errorKind = ErrorKindTimeout
+		message = fmt.Sprintf("No data received for %s", monitor.IdleTimeout)
+	} else if readErr != nil {
+		status = StatusFailed
+		failureReason = FailureDownload
+		errorKind = ErrorKindOther
+		if isTimeout(readErr) {
+			errorKind = ErrorKindTimeout
+		}
+		message = fmt.Sprintf("Error reading response: %v", readErr)
+	} else if f := checkResponse(monitor, resp, body); f != nil {
+		status = StatusFailed
+		failureReason = f.reason
+		message = f.message
+	}
+	// The body is checked last, so it matched if all checks passed
+	bodyMatched := monitor.expectsBody() && readErr == nil && status == StatusSuccess
+	if req.URL.Scheme == "https" && resp.Request.URL.Scheme == "http" {
+		message += " (redirected from https to http)"
+	}
+	if status == StatusSuccess && resp.TLS != nil && monitor.TLSConfig != nil && monitor.TLSConfig.InsecureSkipVerify {
+		status = StatusWarning
+		message += " (certificate not verified)"
+	}
+	certExpiring := status != StatusFailed && certChecked && monitor.CertWarn > 0 && certRemainingValidity < monitor.CertWarn
+	if certExpiring {
+		status = StatusWarning
+		message += fmt.Sprintf(" (certificate expires in %.1f days)", certRemainingValidity.Hours()/24)
+	}
+
+	redirectLocation := ""
+	if isRedirect(resp) {
+		redirectLocation = redirectTarget(resp)
+	}
 
-	// Determine if status code is accepted
-	status := "Success"
-	if !isStatusCodeAccepted(resp.StatusCode, monitor.AcceptedStatusCodes) {
-		status = "Failed"
+	// The skew is only known if the time of the first byte was measured
+	var serverClockSkew *time.Duration
+	if sawFirstByte {
+		serverClockSkew = clockSkew(resp.Header, firstByteTime)
+	}
+
+	var responseHeader http.Header
+	var responseBody []byte
+	if monitor.CaptureResponse {
+		responseHeader = resp.Header
+		responseBody = body
 	}
 
 	// Return the Ping result, including certRemainingValidity if it's a TLS connection
 	return &Ping{
 		Name:                  monitor.Name,
-		URL:                   monitor.URL,
+		URL:                   monitor.pingURL(),
+		RequestID:             requestID,
 		Status:                status,
-		Timestamp:             time.Now(),
+		Timestamp:             e.now(),
 		StatusCode:            resp.StatusCode,
-		Message:               http.StatusText(resp.StatusCode),
+		Message:               message,
 		DNSTime:               dnsDuration,
+		ResolvedAddrs:         resolvedAddrs,
+		Resolver:              monitor.Resolver,
+		RemoteAddr:            remoteAddr,
 		ConnectionTime:        connDuration,
 		TLSTime:               tlsDuration,
 		TTFB:                  ttfb,
+		Received1xx:           received1xx,
+		EarlyHintsTime:        earlyHints,
 		DownloadTime:          downloadTime,
+		ResponseSize:          responseSize,
 		TotalResponseTime:     totalDuration,
 		CertRemainingValidity: certRemainingValidity,
+		CertChecked:           certChecked,
+		CertExpiring:          certExpiring,
+		TLSVersion:            tlsVersion,
+		Protocol:              resp.Proto,
+		CertIssuer:            certIssuer,
+		FinalURL:              resp.Request.URL.String(),
+		Redirects:             redirects,
+		RedirectLocation:      redirectLocation,
+		ContentType:           resp.Header.Get("Content-Type"),
+		ContentEncoding:       resp.Header.Get("Content-Encoding"),
+		BodyMatched:           bodyMatched,
+		CacheStatus:           cacheStatus(resp.Header),
+		ServerClockSkew:       serverClockSkew,
+		FailureReason:         failureReason,
+		ErrorKind:             errorKind,
+		Waterfall:             events.list(),
+		ResponseHeader:        responseHeader,
+		ResponseBody:          responseBody,
+	}, nil
+}
+
+func (e *Engine) transport(monitor *Monitor) http.RoundTripper {
+	if e.Transport != nil {
+		return e.Transport
+	}
+	return transportFor(monitor)
+}
+
+func (e *Engine) now() time.Time {
+	if e.Clock != nil {
+		return e.Clock.Now()
+	}
+	return time.Now()
+}
+
+// sleep waits for the duration or until the context is done
+func (e *Engine) sleep(ctx context.Context, d time.Duration) {
+	if e.Clock != nil {
+		e.Clock.Sleep(ctx, d)
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+func (e *Engine) since(t time.Time) time.Duration {
+	return e.now().Sub(t)
+}
+
+// clockSkew compares the Date header to the local time the response was
+// received. The Date header has a resolution of seconds, so the skew does too.
+// It returns nil if the header is missing or invalid.
+func clockSkew(header http.Header, received time.Time) *time.Duration {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return nil
+	}
+	skew := date.Sub(received.Truncate(time.Second))
+	return &skew
+}
+
+// isPlaintextOnTLSPort reports whether the TLS handshake failed because the
+// server answered in plaintext
+func isPlaintextOnTLSPort(err error) bool {
+	if errors.Is(err, http.ErrSchemeMismatch) {
+		return true
+	}
+	var recordErr tls.RecordHeaderError
+	return errors.As(err, &recordErr)
+}
+
+// isDNSError reports whether resolving the host failed
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// isTimeout reports whether the request failed because a timeout expired
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// pingURL returns the URL reported in the Ping
+func (m *Monitor) pingURL() string {
+	if !m.StripQuery {
+		return m.URL
+	}
+	u, err := url.Parse(m.URL)
+	if err != nil {
+		return m.URL
 	}
+	u.RawQuery = ""
+	u.ForceQuery = false
+	return u.String()
 }
 
 func isStatusCodeAccepted(statusCode int, acceptedStatusCodes []int) bool {
@@ -0,0 +1,27 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import "fmt"
+
+// PingError is returned by ExecutePingE if the request couldn't be created or
+// executed, i.e. no response was received
+type PingError struct {
+	// Reason classifies the error like Ping.FailureReason, e.g. FailureDNS. It
+	// is empty if the error couldn't be classified.
+	Reason string
+	Err    error
+}
+
+func (e *PingError) Error() string {
+	if e.Reason == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *PingError) Unwrap() error {
+	return e.Err
+}
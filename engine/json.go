@@ -0,0 +1,107 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Durations are encoded as integer milliseconds and certificate validities as
+// integer seconds like in the CSV output. Timestamps are encoded as RFC3339.
+
+type pingAlias Ping
+
+type pingJSON struct {
+	*pingAlias
+	Timestamp             string
+	DNSTime               int64
+	ConnectionTime        int64
+	TLSTime               int64
+	TTFB                  int64
+	EarlyHintsTime        int64
+	DownloadTime          int64
+	TotalResponseTime     int64
+	CertRemainingValidity int64
+	ServerClockSkew       *int64 `json:",omitempty"`
+}
+
+func (p *Ping) MarshalJSON() ([]byte, error) {
+	var skew *int64
+	if p.ServerClockSkew != nil {
+		ms := p.ServerClockSkew.Milliseconds()
+		skew = &ms
+	}
+	return json.Marshal(&pingJSON{
+		pingAlias:             (*pingAlias)(p),
+		Timestamp:             p.Timestamp.UTC().Format(time.RFC3339),
+		DNSTime:               p.DNSTime.Milliseconds(),
+		ConnectionTime:        p.ConnectionTime.Milliseconds(),
+		TLSTime:               p.TLSTime.Milliseconds(),
+		TTFB:                  p.TTFB.Milliseconds(),
+		EarlyHintsTime:        p.EarlyHintsTime.Milliseconds(),
+		DownloadTime:          p.DownloadTime.Milliseconds(),
+		TotalResponseTime:     p.TotalResponseTime.Milliseconds(),
+		CertRemainingValidity: int64(p.CertRemainingValidity.Seconds()),
+		ServerClockSkew:       skew,
+	})
+}
+
+func (p *Ping) UnmarshalJSON(b []byte) error {
+	v := &pingJSON{pingAlias: (*pingAlias)(p)}
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+	if v.Timestamp != "" {
+		t, err := time.Parse(time.RFC3339, v.Timestamp)
+		if err != nil {
+			return err
+		}
+		p.Timestamp = t
+	}
+	p.DNSTime = time.Duration(v.DNSTime) * time.Millisecond
+	p.ConnectionTime = time.Duration(v.ConnectionTime) * time.Millisecond
+	p.TLSTime = time.Duration(v.TLSTime) * time.Millisecond
+	p.TTFB = time.Duration(v.TTFB) * time.Millisecond
+	p.EarlyHintsTime = time.Duration(v.EarlyHintsTime) * time.Millisecond
+	p.DownloadTime = time.Duration(v.DownloadTime) * time.Millisecond
+	p.TotalResponseTime = time.Duration(v.TotalResponseTime) * time.Millisecond
+	p.CertRemainingValidity = time.Duration(v.CertRemainingValidity) * time.Second
+	if v.ServerClockSkew != nil {
+		skew := time.Duration(*v.ServerClockSkew) * time.Millisecond
+		p.ServerClockSkew = &skew
+	}
+	return nil
+}
+
+type summaryStatsAlias SummaryStats
+
+func (s *SummaryStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		*summaryStatsAlias
+		FirstMeasurement         string
+		LastMeasurement          string
+		AvgResponseTime          int64
+		MedianResponseTime       int64
+		Percentile95ResponseTime int64
+		Percentile99ResponseTime int64
+		LongestResponseTime      int64
+		ShortestResponseTime     int64
+		StdDevResponseTime       int64
+		ShortestCertValidityTime int64
+	}{
+		summaryStatsAlias:        (*summaryStatsAlias)(s),
+		FirstMeasurement:         s.FirstMeasurement.UTC().Format(time.RFC3339),
+		LastMeasurement:          s.LastMeasurement.UTC().Format(time.RFC3339),
+		AvgResponseTime:          s.AvgResponseTime.Milliseconds(),
+		MedianResponseTime:       s.MedianResponseTime.Milliseconds(),
+		Percentile95ResponseTime: s.Percentile95ResponseTime.Milliseconds(),
+		Percentile99ResponseTime: s.Percentile99ResponseTime.Milliseconds(),
+		LongestResponseTime:      s.LongestResponseTime.Milliseconds(),
+		ShortestResponseTime:     s.ShortestResponseTime.Milliseconds(),
+		StdDevResponseTime:       s.StdDevResponseTime.Milliseconds(),
+		ShortestCertValidityTime: int64(s.ShortestCertValidityTime.Seconds()),
+	})
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import "fmt"
+
+// Logger receives the events of a ping, e.g. to debug why it failed
+type Logger interface {
+	// Log is called for each event. Level 1 is used for the main events like a
+	// connection being established, level 2 for details.
+	Log(level int, monitor *Monitor, message string)
+}
+
+func (e *Engine) log(level int, monitor *Monitor, format string, a ...any) {
+	if e.Logger != nil {
+		e.Logger.Log(level, monitor, fmt.Sprintf(format, a...))
+	}
+}
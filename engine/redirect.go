@@ -0,0 +1,60 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Redirect is a response redirecting the request to another URL
+type Redirect struct {
+	URL        string // URL which responded with the redirect
+	StatusCode int
+}
+
+// redirectError aborts a redirect that didn't pass a check
+type redirectError struct {
+	reason string
+	target string
+}
+
+func (e *redirectError) Error() string {
+	return fmt.Sprintf("redirect to %s not allowed (%s)", e.target, e.reason)
+}
+
+// checkRedirect stops following redirects after MaxRedirects hops. If the
+// monitor fails on redirects or doesn't follow them, the redirect response is
+// returned instead.
+func (m *Monitor) checkRedirect(req *http.Request, via []*http.Request) error {
+	if m.FailOnRedirect || m.NoFollow {
+		return http.ErrUseLastResponse
+	}
+	if m.NoCrossHostRedirect && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return &redirectError{
+			reason: FailureCrossHostRedirect,
+			target: req.URL.String(),
+		}
+	}
+	if len(via) > m.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", m.MaxRedirects)
+	}
+	return nil
+}
+
+// isRedirect reports whether the response redirects to another location
+func isRedirect(resp *http.Response) bool {
+	return resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != ""
+}
+
+// redirectTarget returns the absolute URL a response redirects to
+func redirectTarget(resp *http.Response) string {
+	u, err := resp.Location()
+	if err != nil {
+		return resp.Header.Get("Location")
+	}
+	return u.String()
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// ExecutePing takes a Monitor and produces a Ping. Failed requests are retried
+// up to Retries times, waiting RetryInterval seconds in between, unless the
+// RetryBudget is exhausted. With Retries of 0 the request is attempted once.
+// The Ping of the last attempt is returned, Ping.Attempts counts the attempts.
+func (e *Engine) ExecutePing(monitor *Monitor) *Ping {
+	return e.ExecutePingContext(context.Background(), monitor)
+}
+
+// ExecutePingContext is like ExecutePing but aborts the request and retries
+// when the context is done
+func (e *Engine) ExecutePingContext(ctx context.Context, monitor *Monitor) *Ping {
+	ping, _ := e.executePing(ctx, monitor)
+	return ping
+}
+
+// ExecutePingE is like ExecutePing but also returns a *PingError if the last
+// attempt received no response. Failures of the response, like an unexpected
+// status code, are only reported in the Ping.
+func (e *Engine) ExecutePingE(monitor *Monitor) (*Ping, error) {
+	return e.executePing(context.Background(), monitor)
+}
+
+func (e *Engine) executePing(ctx context.Context, monitor *Monitor) (*Ping, error) {
+	start := e.now()
+	interval := time.Duration(monitor.RetryInterval) * time.Second
+	retriedOnAssertion := false
+	for attempt := 1; ; attempt++ {
+		ping, err := e.executeAuthenticatedAttempt(ctx, monitor)
+		ping.Attempts = attempt
+		ping.Labels = monitor.Labels
+		ping.RetriedOnAssertion = retriedOnAssertion
+		if !isRetryable(monitor, ping) || attempt > monitor.Retries || ctx.Err() != nil {
+			return ping, err
+		}
+		if monitor.RetryBudget > 0 && e.since(start)+interval > monitor.RetryBudget {
+			ping.RetryBudgetExhausted = true
+			ping.Message += " (retry budget exhausted)"
+			return ping, err
+		}
+		retriedOnAssertion = retriedOnAssertion || isAssertionFailure(ping.FailureReason)
+		e.log(1, monitor, "attempt %d failed: %s, retrying in %s", attempt, ping.Message, interval)
+		e.sleep(ctx, interval)
+		if ctx.Err() != nil {
+			// Report the last attempt rather than one aborted right away
+			return ping, err
+		}
+	}
+}
+
+// isRetryable reports whether a failed ping should be retried. Pings failing
+// because of the request or the status code are retried, failed assertions on
+// the response only if the monitor asks for it.
+func isRetryable(monitor *Monitor, ping *Ping) bool {
+	if ping.Status != StatusFailed {
+		return false
+	}
+	return monitor.RetryOnAssertion || !isAssertionFailure(ping.FailureReason)
+}
+
+// isAssertionFailure reports whether a failure reason is a failed check of the
+// response rather than a failed request
+func isAssertionFailure(reason string) bool {
+	switch reason {
+	case FailureRedirectTarget, FailureContentType, FailureSchema, FailureCertInfo, FailureCORS, FailureCrossHostRedirect, FailureUnexpectedRedirect, FailureCache, FailureBody:
+		return true
+	default:
+		return false
+	}
+}
@@ -5,100 +5,221 @@
 package engine
 
 import (
+	"math"
 	"slices"
 	"sort"
 	"strings"
 	"time"
 )
 
+// SummaryStats are the statistics of an endpoint. Availability only counts
+// successful measurements, warnings are reported separately. Availability and
+// the counts cover all measurements, the response times and the worst monitor
+// only measurements which didn't fail, since failed requests have no or
+// incomplete timings. Without such measurements the response times are zero.
 type SummaryStats struct {
-	Endpoint                   string
-	Availability               float64
-	AvgResponseTime            time.Duration
-	MedianResponseTime         time.Duration
-	Percentile99ResponseTime   time.Duration
-	LongestResponseTime        time.Duration
-	ShortestCertValidityTime   time.Duration
-	WorstMonitor               string
-	NumberOfMeasurements       int
-	NumberOfFailedMeasurements int
-	MonitoringDuration         string
+	Endpoint                    string // empty if grouped by monitor name only
+	Monitor                     string `json:",omitempty"` // only set if grouped by monitor name
+	Availability                float64
+	WarningRate                 float64
+	AvgResponseTime             time.Duration
+	MedianResponseTime          time.Duration
+	Percentile95ResponseTime    time.Duration
+	Percentile99ResponseTime    time.Duration
+	LongestResponseTime         time.Duration
+	ShortestResponseTime        time.Duration
+	StdDevResponseTime          time.Duration // population standard deviation, the jitter
+	ResponseTimeVariation       float64       // coefficient of variation in percent
+	ShortestCertValidityTime    time.Duration
+	WorstMonitor                string
+	NumberOfMeasurements        int
+	NumberOfFailedMeasurements  int
+	NumberOfWarningMeasurements int
+	FailuresByKind              map[string]int `json:",omitempty"` // failed measurements per Ping.ErrorKind
+	CacheHitRate                float64        // of the measurements with a known cache status
+	MonitoringDuration          string         // time between the first and the last measurement
+	FirstMeasurement            time.Time
+	LastMeasurement             time.Time
+
+	// responseTimes are the sorted response times in milliseconds
+	responseTimes []int
+}
+
+// Percentile returns the p-th percentile (0 < p <= 100) of the response times
+func (s *SummaryStats) Percentile(p float64) time.Duration {
+	return time.Duration(percentile(s.responseTimes, p/100)) * time.Millisecond
+}
+
+// Values of the grouping of SummarizeBy
+const (
+	GroupByURL  = "url"
+	GroupByName = "name"
+	GroupByBoth = "both"
+)
+
+// summaryKey identifies a group of pings
+type summaryKey struct {
+	name string
+	url  string
 }
 
+// Summarize calculates statistics per endpoint. Endpoints without any
+// measurements are omitted from the result.
 func Summarize(pings []*Ping) []*SummaryStats {
-	index := make(map[string]*SummaryStats)
-	endpointsData := make(map[string][]*Ping)
+	return SummarizeBy(pings, GroupByURL)
+}
 
-	// Group pings by endpoint
+// SummarizeBy calculates statistics per URL, per monitor name or per pair of
+// both, depending on groupBy. Groups without any measurements are omitted
+// from the result.
+func SummarizeBy(pings []*Ping, groupBy string) []*SummaryStats {
+	index := make(map[summaryKey]*SummaryStats)
+	endpointsData := make(map[summaryKey][]*Ping)
+
+	// Group pings
 	for _, p := range pings {
-		endpointsData[p.URL] = append(endpointsData[p.URL], p)
+		var key summaryKey
+		if groupBy != GroupByName {
+			key.url = p.URL
+		}
+		if groupBy == GroupByName || groupBy == GroupByBoth {
+			key.name = p.Name
+		}
+		endpointsData[key] = append(endpointsData[key], p)
 	}
 
-	// Calculate statistics per endpoint
-	for endpoint, data := range endpointsData {
-		var totalResponseTime, successCount, longestResponseTime, shortestCertValidity, failedCount int
+	// Calculate statistics per group
+	for key, data := range endpointsData {
+		if len(data) == 0 {
+			continue
+		}
+		var totalResponseTime, timedCount, successCount, longestResponseTime, failedCount, warningCount int
+		shortestResponseTime := -1
+		var shortestCertValidity time.Duration
+		var cacheHits, cacheKnown int
+		var failuresByKind map[string]int
+		first, last := data[0].Timestamp, data[0].Timestamp
 		var responseTimes []int
-		shortestCertValidity = int(^uint(0) >> 1) // Set to max int initially
 		var worstMonitorName string
 		worstPerformance := 0
 
 		for _, p := range data {
-			pTotalResponseTime := int(p.TotalResponseTime.Milliseconds())
-			totalResponseTime += pTotalResponseTime
-			responseTimes = append(responseTimes, pTotalResponseTime)
-			if p.Status == "Success" {
+			switch p.Status {
+			case StatusSuccess:
 				successCount++
-			} else {
+			case StatusWarning:
+				warningCount++
+			default:
 				failedCount++
+				if p.ErrorKind != "" {
+					if failuresByKind == nil {
+						failuresByKind = make(map[string]int)
+					}
+					failuresByKind[p.ErrorKind]++
+				}
+			}
+			if p.Timestamp.Before(first) {
+				first = p.Timestamp
+			}
+			if p.Timestamp.After(last) {
+				last = p.Timestamp
+			}
+			switch p.CacheStatus {
+			case CacheHit:
+				cacheHits++
+				cacheKnown++
+			case CacheMiss:
+				cacheKnown++
+			}
+			// A validity of 0 means no certificate information was available
+			if p.CertRemainingValidity != 0 && (shortestCertValidity == 0 || p.CertRemainingValidity < shortestCertValidity) {
+				shortestCertValidity = p.CertRemainingValidity
 			}
-			if int(p.TotalResponseTime) > longestResponseTime {
+			if p.Status == StatusFailed {
+				continue
+			}
+
+			pTotalResponseTime := int(p.TotalResponseTime.Milliseconds())
+			timedCount++
+			totalResponseTime += pTotalResponseTime
+			responseTimes = append(responseTimes, pTotalResponseTime)
+			if pTotalResponseTime > longestResponseTime {
 				longestResponseTime = pTotalResponseTime
 			}
-			if int(p.CertRemainingValidity) < shortestCertValidity {
-				shortestCertValidity = int(p.CertRemainingValidity.Seconds())
+			if shortestResponseTime < 0 || pTotalResponseTime < shortestResponseTime {
+				shortestResponseTime = pTotalResponseTime
 			}
 			// Determine the worst monitor based on response time
-			if int(p.TotalResponseTime) > worstPerformance {
+			if worstMonitorName == "" || pTotalResponseTime > worstPerformance {
 				worstPerformance = pTotalResponseTime
 				worstMonitorName = p.Name
 			}
 		}
 
-		// Sort response times to calculate median and 99th percentile
+		// Sort response times to calculate median and percentiles
 		sort.Ints(responseTimes)
 		var medianResponseTime float64
-		var percentile99ResponseTime int
 		if len(responseTimes) > 0 {
 			medianResponseTime = float64(responseTimes[len(responseTimes)/2])
-			percentileIndex := int(float64(len(responseTimes))*0.99) - 1
-			if percentileIndex < 0 {
-				percentileIndex = 0
-			}
-			percentile99ResponseTime = responseTimes[percentileIndex]
 		}
+		percentile95ResponseTime := percentile(responseTimes, 0.95)
+		percentile99ResponseTime := percentile(responseTimes, 0.99)
 
 		// Calculate availability
 		availability := (float64(successCount) / float64(len(data))) * 100
+		warningRate := (float64(warningCount) / float64(len(data))) * 100
+		var cacheHitRate float64
+		if cacheKnown > 0 {
+			cacheHitRate = (float64(cacheHits) / float64(cacheKnown)) * 100
+		}
 
-		// Calculate average response time
-		avgResponseTime := float64(totalResponseTime) / float64(len(data))
+		// Calculate average response time, the standard deviation and the
+		// coefficient of variation
+		var avgResponseTime, stdDevResponseTime, responseTimeVariation float64
+		if timedCount > 0 {
+			avgResponseTime = float64(totalResponseTime) / float64(timedCount)
+			// Sum the squared deviations from the average, subtracting the
+			// squared average from the average square loses precision
+			var sumOfSquares float64
+			for _, rt := range responseTimes {
+				d := float64(rt) - avgResponseTime
+				sumOfSquares += d * d
+			}
+			stdDevResponseTime = math.Sqrt(sumOfSquares / float64(timedCount))
+		}
+		if avgResponseTime > 0 {
+			responseTimeVariation = stdDevResponseTime / avgResponseTime * 100
+		}
+		shortestResponseTime = max(0, shortestResponseTime)
 
 		// Determine monitoring duration
-		monitoringDuration := "unknown" // Placeholder; calculation can be done based on timestamps if available
+		monitoringDuration := last.Sub(first).Round(time.Second).String()
 
 		// Store stats
-		index[endpoint] = &SummaryStats{
-			Endpoint:                   endpoint,
-			Availability:               availability,
-			AvgResponseTime:            time.Duration(avgResponseTime) * time.Millisecond,
-			MedianResponseTime:         time.Duration(medianResponseTime) * time.Millisecond,
-			Percentile99ResponseTime:   time.Duration(percentile99ResponseTime) * time.Millisecond,
-			LongestResponseTime:        time.Duration(longestResponseTime) * time.Millisecond,
-			ShortestCertValidityTime:   time.Duration(shortestCertValidity) * time.Millisecond,
-			WorstMonitor:               worstMonitorName,
-			NumberOfMeasurements:       len(data),
-			NumberOfFailedMeasurements: failedCount,
-			MonitoringDuration:         monitoringDuration,
+		index[key] = &SummaryStats{
+			Endpoint:                    key.url,
+			Monitor:                     key.name,
+			Availability:                availability,
+			WarningRate:                 warningRate,
+			AvgResponseTime:             time.Duration(avgResponseTime) * time.Millisecond,
+			MedianResponseTime:          time.Duration(medianResponseTime) * time.Millisecond,
+			Percentile95ResponseTime:    time.Duration(percentile95ResponseTime) * time.Millisecond,
+			Percentile99ResponseTime:    time.Duration(percentile99ResponseTime) * time.Millisecond,
+			LongestResponseTime:         time.Duration(longestResponseTime) * time.Millisecond,
+			ShortestResponseTime:        time.Duration(shortestResponseTime) * time.Millisecond,
+			StdDevResponseTime:          time.Duration(stdDevResponseTime * float64(time.Millisecond)),
+			ResponseTimeVariation:       responseTimeVariation,
+			ShortestCertValidityTime:    shortestCertValidity,
+			WorstMonitor:                worstMonitorName,
+			NumberOfMeasurements:        len(data),
+			NumberOfFailedMeasurements:  failedCount,
+			NumberOfWarningMeasurements: warningCount,
+			FailuresByKind:              failuresByKind,
+			CacheHitRate:                cacheHitRate,
+			MonitoringDuration:          monitoringDuration,
+			FirstMeasurement:            first,
+			LastMeasurement:             last,
+			responseTimes:               responseTimes,
 		}
 	}
 
@@ -108,8 +229,34 @@ func Summarize(pings []*Ping) []*SummaryStats {
 	}
 
 	slices.SortFunc(stats, func(a, b *SummaryStats) int {
+		if c := strings.Compare(a.Monitor, b.Monitor); c != 0 {
+			return c
+		}
 		return strings.Compare(a.Endpoint, b.Endpoint)
 	})
 
 	return stats
 }
+
+// percentile returns the p-th percentile (0 < p <= 1) of sorted values using
+// the nearest-rank method: the smallest value which is greater than or equal
+// to p of all values
+func percentile(sorted []int, p float64) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	rank = max(1, min(rank, len(sorted)))
+	return sorted[rank-1]
+}
+
+// Bucket groups pings into buckets of the given size by their timestamp. The
+// key is the start of the bucket.
+func Bucket(pings []*Ping, size time.Duration) map[time.Time][]*Ping {
+	buckets := make(map[time.Time][]*Ping)
+	for _, p := range pings {
+		start := p.Timestamp.Truncate(size)
+		buckets[start] = append(buckets[start], p)
+	}
+	return buckets
+}
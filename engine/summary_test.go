@@ -0,0 +1,250 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"maps"
+	"math"
+	"testing"
+	"time"
+)
+
+// ping creates a ping of the given URL and status with a response time in
+// milliseconds
+func ping(url, status string, ms int) *Ping {
+	return &Ping{
+		Name:              "test",
+		URL:               url,
+		Status:            status,
+		Timestamp:         time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		TotalResponseTime: time.Duration(ms) * time.Millisecond,
+	}
+}
+
+func TestSummarizeWithoutPings(t *testing.T) {
+	for _, pings := range [][]*Ping{nil, {}} {
+		if stats := Summarize(pings); len(stats) != 0 {
+			t.Errorf("Summarize(%v) = %d stats, want none", pings, len(stats))
+		}
+	}
+}
+
+func TestSummarizeSinglePing(t *testing.T) {
+	stats := Summarize([]*Ping{ping("https://example.com", StatusSuccess, 120)})
+	if len(stats) != 1 {
+		t.Fatalf("got %d stats, want 1", len(stats))
+	}
+	s := stats[0]
+	if s.Endpoint != "https://example.com" {
+		t.Errorf("Endpoint = %s, want https://example.com", s.Endpoint)
+	}
+	if s.Availability != 100 {
+		t.Errorf("Availability = %g, want 100", s.Availability)
+	}
+	want := 120 * time.Millisecond
+	for name, got := range map[string]time.Duration{
+		"AvgResponseTime":          s.AvgResponseTime,
+		"MedianResponseTime":       s.MedianResponseTime,
+		"Percentile95ResponseTime": s.Percentile95ResponseTime,
+		"Percentile99ResponseTime": s.Percentile99ResponseTime,
+		"LongestResponseTime":      s.LongestResponseTime,
+		"ShortestResponseTime":     s.ShortestResponseTime,
+	} {
+		if got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+	if s.StdDevResponseTime != 0 {
+		t.Errorf("StdDevResponseTime = %s, want 0", s.StdDevResponseTime)
+	}
+	if s.NumberOfMeasurements != 1 || s.NumberOfFailedMeasurements != 0 {
+		t.Errorf("measurements = %d, failed = %d, want 1 and 0", s.NumberOfMeasurements, s.NumberOfFailedMeasurements)
+	}
+	if s.WorstMonitor != "test" {
+		t.Errorf("WorstMonitor = %s, want test", s.WorstMonitor)
+	}
+}
+
+// Failed pings have no timings, so a group of failed pings has no response
+// times to summarize
+func TestSummarizeOnlyFailedPings(t *testing.T) {
+	for _, n := range []int{1, 3} {
+		pings := make([]*Ping, n)
+		for i := range pings {
+			pings[i] = ping("https://example.com", StatusFailed, 0)
+		}
+		stats := Summarize(pings)
+		if len(stats) != 1 {
+			t.Fatalf("got %d stats, want 1", len(stats))
+		}
+		s := stats[0]
+		if s.Availability != 0 {
+			t.Errorf("Availability = %g, want 0", s.Availability)
+		}
+		if s.NumberOfFailedMeasurements != n {
+			t.Errorf("NumberOfFailedMeasurements = %d, want %d", s.NumberOfFailedMeasurements, n)
+		}
+		for name, got := range map[string]time.Duration{
+			"AvgResponseTime":          s.AvgResponseTime,
+			"MedianResponseTime":       s.MedianResponseTime,
+			"Percentile99ResponseTime": s.Percentile99ResponseTime,
+			"LongestResponseTime":      s.LongestResponseTime,
+			"ShortestResponseTime":     s.ShortestResponseTime,
+			"StdDevResponseTime":       s.StdDevResponseTime,
+		} {
+			if got != 0 {
+				t.Errorf("%s = %s, want 0", name, got)
+			}
+		}
+		if s.ResponseTimeVariation != 0 {
+			t.Errorf("ResponseTimeVariation = %g, want 0", s.ResponseTimeVariation)
+		}
+		if s.WorstMonitor != "" {
+			t.Errorf("WorstMonitor = %s, want none", s.WorstMonitor)
+		}
+		if got := s.Percentile(90); got != 0 {
+			t.Errorf("Percentile(90) = %s, want 0", got)
+		}
+	}
+}
+
+func TestPercentileWithoutValues(t *testing.T) {
+	for _, p := range []float64{0.5, 0.95, 0.99, 1} {
+		if got := percentile(nil, p); got != 0 {
+			t.Errorf("percentile(nil, %g) = %d, want 0", p, got)
+		}
+	}
+}
+
+func TestSummarizeLongestAndWorst(t *testing.T) {
+	pings := []*Ping{
+		ping("https://example.com", StatusSuccess, 120),
+		ping("https://example.com", StatusSuccess, 2500),
+		ping("https://example.com", StatusWarning, 900),
+		// Failed pings don't count, even with a longer response time
+		ping("https://example.com", StatusFailed, 5000),
+		ping("https://example.com", StatusSuccess, 7),
+	}
+	for i, name := range []string{"fra", "nyc", "sgp", "syd", "lon"} {
+		pings[i].Name = name
+	}
+
+	stats := Summarize(pings)
+	if len(stats) != 1 {
+		t.Fatalf("got %d stats, want 1", len(stats))
+	}
+	s := stats[0]
+	if want := 2500 * time.Millisecond; s.LongestResponseTime != want {
+		t.Errorf("LongestResponseTime = %s, want %s", s.LongestResponseTime, want)
+	}
+	if want := 7 * time.Millisecond; s.ShortestResponseTime != want {
+		t.Errorf("ShortestResponseTime = %s, want %s", s.ShortestResponseTime, want)
+	}
+	if s.WorstMonitor != "nyc" {
+		t.Errorf("WorstMonitor = %s, want nyc", s.WorstMonitor)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = i + 1
+	}
+	tests := []struct {
+		values        []int
+		p50, p95, p99 int
+	}{
+		{[]int{10}, 10, 10, 10},
+		{[]int{10, 20}, 10, 20, 20},
+		{hundred, 50, 95, 99},
+	}
+	for _, tt := range tests {
+		for p, want := range map[float64]int{0.5: tt.p50, 0.95: tt.p95, 0.99: tt.p99} {
+			if got := percentile(tt.values, p); got != want {
+				t.Errorf("percentile of %d values at %g = %d, want %d", len(tt.values), p, got, want)
+			}
+		}
+	}
+}
+
+func TestSummaryStatsPercentile(t *testing.T) {
+	pings := make([]*Ping, 100)
+	for i := range pings {
+		pings[i] = ping("https://example.com", StatusSuccess, 100-i)
+	}
+	s := Summarize(pings)[0]
+	for p, want := range map[float64]time.Duration{50: 50, 90: 90, 95: 95, 99: 99, 100: 100} {
+		want *= time.Millisecond
+		if got := s.Percentile(p); got != want {
+			t.Errorf("Percentile(%g) = %s, want %s", p, got, want)
+		}
+	}
+	if s.Percentile95ResponseTime != 95*time.Millisecond || s.Percentile99ResponseTime != 99*time.Millisecond {
+		t.Errorf("P95 = %s, P99 = %s, want 95ms and 99ms", s.Percentile95ResponseTime, s.Percentile99ResponseTime)
+	}
+}
+
+func TestSummarizeStdDev(t *testing.T) {
+	tests := []struct {
+		name      string
+		ms        []int
+		stdDev    time.Duration
+		variation float64
+	}{
+		// Mean 500ms, population standard deviation 200ms
+		{"known distribution", []int{200, 400, 400, 400, 500, 500, 700, 900}, 200 * time.Millisecond, 40},
+		{"constant", []int{300, 300, 300}, 0, 0},
+		// Large values with a small spread, where E[x²]-mean² loses precision
+		{"large values", []int{100_000_000, 100_000_002, 100_000_000, 100_000_002}, time.Millisecond, 1e-6},
+	}
+	for _, tt := range tests {
+		pings := make([]*Ping, len(tt.ms))
+		for i, ms := range tt.ms {
+			pings[i] = ping("https://example.com", StatusSuccess, ms)
+		}
+		s := Summarize(pings)[0]
+		if s.StdDevResponseTime != tt.stdDev {
+			t.Errorf("%s: StdDevResponseTime = %s, want %s", tt.name, s.StdDevResponseTime, tt.stdDev)
+		}
+		if math.Abs(s.ResponseTimeVariation-tt.variation) > 1e-9 {
+			t.Errorf("%s: ResponseTimeVariation = %g, want %g", tt.name, s.ResponseTimeVariation, tt.variation)
+		}
+	}
+}
+
+// Failed pings aren't part of the response time distribution
+func TestSummarizeStdDevIgnoresFailures(t *testing.T) {
+	s := Summarize([]*Ping{
+		ping("https://example.com", StatusSuccess, 100),
+		ping("https://example.com", StatusWarning, 300),
+		ping("https://example.com", StatusFailed, 10000),
+	})[0]
+	if want := 100 * time.Millisecond; s.StdDevResponseTime != want {
+		t.Errorf("StdDevResponseTime = %s, want %s", s.StdDevResponseTime, want)
+	}
+}
+
+func TestSummarizeFailuresByKind(t *testing.T) {
+	failed := func(kind string) *Ping {
+		p := ping("https://example.com", StatusFailed, 0)
+		p.ErrorKind = kind
+		return p
+	}
+	s := Summarize([]*Ping{
+		ping("https://example.com", StatusSuccess, 100),
+		failed(ErrorKindTimeout),
+		failed(ErrorKindDNS),
+		failed(ErrorKindTimeout),
+		// Failures of the response itself have no kind
+		failed(""),
+	})[0]
+	want := map[string]int{ErrorKindTimeout: 2, ErrorKindDNS: 1}
+	if !maps.Equal(s.FailuresByKind, want) {
+		t.Errorf("FailuresByKind = %v, want %v", s.FailuresByKind, want)
+	}
+	if s.NumberOfFailedMeasurements != 4 {
+		t.Errorf("NumberOfFailedMeasurements = %d, want 4", s.NumberOfFailedMeasurements)
+	}
+}
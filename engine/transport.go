@@ -0,0 +1,109 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// transportKey identifies monitors that can share a transport
+type transportKey struct {
+	connectTimeout time.Duration
+	keepAlive      bool
+	maxConns       int
+	maxIdleConns   int
+	noCompression  bool
+	localAddr      string
+	resolver       string
+	proxy          string
+	headerTimeout  time.Duration
+	tlsConfig      *tls.Config
+	http2          bool
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[transportKey]*http.Transport)
+)
+
+// transportFor returns a shared transport matching the monitor's settings.
+// Unless the monitor asks for keep-alives, each ping measures DNS, connection
+// and TLS handshake of a fresh connection.
+func transportFor(monitor *Monitor) *http.Transport {
+	key := transportKey{
+		connectTimeout: monitor.ConnectTimeout,
+		keepAlive:      monitor.KeepAlive,
+		maxConns:       monitor.MaxConnsPerHost,
+		maxIdleConns:   monitor.MaxIdleConnsPerHost,
+		noCompression:  monitor.AcceptEncoding != "",
+		tlsConfig:      monitor.TLSConfig,
+		http2:          monitor.HTTP2,
+		resolver:       monitor.Resolver,
+	}
+	if monitor.Proxy != nil {
+		key.proxy = monitor.Proxy.String()
+	}
+	if monitor.IdleTimeout > 0 {
+		key.headerTimeout = monitor.ResponseTimeout
+	}
+	if monitor.LocalAddr != nil {
+		key.localAddr = monitor.LocalAddr.String()
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	if t, ok := transports[key]; ok {
+		return t
+	}
+
+	dialer := &net.Dialer{
+		Timeout: key.connectTimeout,
+	}
+	if monitor.LocalAddr != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: monitor.LocalAddr}
+	}
+	if key.resolver != "" {
+		dialer.Resolver = newResolver(key.resolver, key.connectTimeout)
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if monitor.Proxy != nil {
+		proxy = http.ProxyURL(monitor.Proxy)
+	}
+
+	// Create a custom HTTP transport with separate connect and response timeouts
+	t := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   key.connectTimeout, // Apply the connect timeout to the TLS handshake
+		DisableKeepAlives:     !key.keepAlive,
+		MaxConnsPerHost:       key.maxConns,
+		MaxIdleConnsPerHost:   key.maxIdleConns,
+		DisableCompression:    key.noCompression,
+		ResponseHeaderTimeout: key.headerTimeout,
+		TLSClientConfig:       key.tlsConfig,
+		ForceAttemptHTTP2:     key.http2,
+	}
+	transports[key] = t
+	return t
+}
+
+// newResolver creates a resolver which sends its queries to the DNS server at
+// addr instead of the servers configured in the system
+func newResolver(addr string, timeout time.Duration) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 Christoph Fichtmüller. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Waterfall event names
+const (
+	EventStart        = "start"
+	EventDNSStart     = "dns_start"
+	EventDNSDone      = "dns_done"
+	EventConnectStart = "connect_start"
+	EventConnectDone  = "connect_done"
+	EventTLSStart     = "tls_start"
+	EventTLSDone      = "tls_done"
+	EventFirstByte    = "first_byte"
+	EventBodyDone     = "body_done"
+)
+
+// WaterfallEvent is a trace event at an absolute point in time
+type WaterfallEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+}
+
+// waterfall collects trace events if enabled. Connects to several addresses
+// may be traced concurrently.
+type waterfall struct {
+	enabled bool
+	mu      sync.Mutex
+	events  []WaterfallEvent
+}
+
+func (w *waterfall) add(event string, t time.Time) {
+	if !w.enabled {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, WaterfallEvent{Event: event, Time: t})
+}
+
+func (w *waterfall) list() []WaterfallEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.events
+}